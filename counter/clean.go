@@ -0,0 +1,155 @@
+package counter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const DefaultSampleCount = 120
+
+// DefaultRetention: 每个精度保留的样本数, 没有配置的精度保留 DefaultSampleCount 个
+var DefaultRetention = map[int]int{
+	1:     120, // 2 分钟
+	5:     120, // 10 分钟
+	60:    120, // 2 小时
+	300:   288, // 1 天
+	3600:  720, // 30 天
+	18000: 144, // 30 天
+	86400: 365, // 1 年
+}
+
+func sampleCount(retention map[int]int, precision int) int {
+	if n, ok := retention[precision]; ok {
+		return n
+	}
+	return DefaultSampleCount
+}
+
+func oldFields(fields []string, cutoff int64) []string {
+	old := []string{}
+	for _, field := range fields {
+		ts, err := strconv.ParseInt(field, 10, 64)
+		if err == nil && ts < cutoff {
+			old = append(old, field)
+		}
+	}
+	return old
+}
+
+// withoutDirty: Rollup 模式下最细精度还没汇总的时间桶不能删除
+func withoutDirty(old []string, dirty []string) []string {
+	if len(dirty) == 0 {
+		return old
+	}
+	skip := map[string]bool{}
+	for _, field := range dirty {
+		skip[field] = true
+	}
+	kept := []string{}
+	for _, field := range old {
+		if !skip[field] {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// cleanCounter: 删除过期的样本, 计数器为空时从 countList 中移除
+func (counter *Counter) cleanCounter(info *CounterInfo, keep int) error {
+	ctx := context.Background()
+	key := counter.GetCounterKey(info.Precision, info.Name)
+	rolledKey := counter.GetCountRolledKey(info.Precision, info.Name)
+	dirtyKey := counter.GetCountDirtyKey(info.Name)
+	cutoff := counter.now().Unix() - int64(keep*info.Precision)
+	checkDirty := counter.Rollup && info.Precision == counter.finestPrecision(info.Name)
+	dirtyRange := &redis.ZRangeBy{Min: "-inf", Max: "(" + strconv.FormatInt(cutoff, 10)}
+	fields, err := counter.Client.HKeys(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	old := oldFields(fields, cutoff)
+	if checkDirty && len(old) > 0 {
+		dirty, err := counter.Client.ZRangeByScore(ctx, dirtyKey, dirtyRange).Result()
+		if err != nil {
+			return err
+		}
+		old = withoutDirty(old, dirty)
+	}
+	// 只删除旧的时间桶, 并发写入的都是新的时间桶, 不会冲突
+	if len(old) < len(fields) {
+		if len(old) == 0 {
+			return nil
+		}
+		_, err := counter.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, key, old...)
+			pipe.HDel(ctx, rolledKey, old...)
+			return nil
+		})
+		return err
+	}
+	// 全部过期或为空: watch 住 key 后重新读取, 防止删除时有新的写入或时间桶重新变脏
+	txf := func(tx *redis.Tx) error {
+		fields, err := tx.HKeys(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		old := oldFields(fields, cutoff)
+		if checkDirty && len(old) > 0 {
+			dirty, err := tx.ZRangeByScore(ctx, dirtyKey, dirtyRange).Result()
+			if err != nil {
+				return err
+			}
+			old = withoutDirty(old, dirty)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if len(old) < len(fields) {
+				if len(old) > 0 {
+					pipe.HDel(ctx, key, old...)
+					pipe.HDel(ctx, rolledKey, old...)
+				}
+				return nil
+			}
+			pipe.Del(ctx, key, rolledKey)
+			pipe.ZRem(ctx, counter.GetCountListKey(), counter.getCountListMember(info.Precision, info.Name))
+			return nil
+		})
+		return err
+	}
+	err = counter.Client.Watch(ctx, txf, key, dirtyKey)
+	if err == redis.TxFailedErr {
+		// 有新的写入, 下一轮再清理
+		return nil
+	}
+	return err
+}
+
+// CleanCounters: 清理一遍所有登记的计数器
+func (counter *Counter) CleanCounters(retention map[int]int) error {
+	infos, err := counter.ListCounters()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := counter.cleanCounter(info, sampleCount(retention, info.Precision)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunCleaner: 每 interval 清理一次, 直到 ctx 结束
+func (counter *Counter) RunCleaner(ctx context.Context, interval time.Duration, retention map[int]int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			counter.CleanCounters(retention)
+		}
+	}
+}
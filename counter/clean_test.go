@@ -0,0 +1,49 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCounter_CleanCounters(t *testing.T) {
+	counter := NewCounter()
+	ctx := context.Background()
+	key := counter.GetCounterKey(60, "clean")
+	counter.Client.Del(ctx, key)
+	old := time.Now().Add(-time.Hour).Unix() / 60 * 60
+	counter.Client.HSet(ctx, key, old, 1)
+	counter.Client.ZAdd(ctx, counter.GetCountListKey(), &redis.Z{Score: 60, Member: counter.getCountListMember(60, "clean")})
+	if err := counter.CleanCounters(map[int]int{60: 10}); err != nil {
+		t.Error(err)
+	}
+	if counter.Client.Exists(ctx, key).Val() != 0 {
+		t.Error("err")
+	}
+	if counter.Client.ZScore(ctx, counter.GetCountListKey(), counter.getCountListMember(60, "clean")).Err() != redis.Nil {
+		t.Error("err")
+	}
+}
+
+func TestCounter_CleanCountersDirty(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{1, 60})
+	counter.Rollup = true
+	ctx := context.Background()
+	key := counter.GetCounterKey(1, "cleanDirty")
+	counter.Client.Del(ctx, key, counter.GetCountDirtyKey("cleanDirty"))
+	counter.CounterIncrAt("cleanDirty", 1, time.Now().Add(-time.Hour))
+	if err := counter.CleanCounters(map[int]int{1: 10}); err != nil {
+		t.Error(err)
+	}
+	// 还没汇总的时间桶不能删除
+	if counter.Client.HLen(ctx, key).Val() != 1 {
+		t.Error("err")
+	}
+	counter.RollupCounters()
+	counter.CleanCounters(map[int]int{1: 10})
+	if counter.Client.Exists(ctx, key).Val() != 0 {
+		t.Error("err")
+	}
+}
@@ -3,7 +3,10 @@ package counter
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -18,48 +21,202 @@ func initRedisClient() *redis.Client {
 	return redisCli
 }
 
+// Clock: 当前时间, 测试时可以替换
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
 type Counter struct {
-	Client *redis.Client
+	Client           *redis.Client
+	Clock            Clock
+	DefaultPrecision []int // 没有单独配置的计数器使用的精度, 为空时使用 PRECISION
+	Rollup           bool  // 只写最细的精度, 由 RunRollup 汇总到粗精度
+
+	mu         sync.RWMutex
+	precisions map[string][]int // 每个计数器的精度, 通过 SetPrecisions 配置
 }
 
 func NewCounter() *Counter {
-	return &Counter{Client: initRedisClient()}
+	return NewCounterWithPrecision(PRECISION)
+}
+
+func NewCounterWithPrecision(precision []int) *Counter {
+	return &Counter{
+		Client:           initRedisClient(),
+		Clock:            systemClock{},
+		DefaultPrecision: append([]int{}, precision...),
+	}
 }
 
 /*
-count:1:hits  -----   hash
+count:$precision:$name  -----   hash
+count:1:hits
 100000: 126
 100001: 128
 
-countList  ----- zset
+countList  ----- zset  // 所有计数器, 用于查询和清理
 1:hits    1
 5:hits    5
+5:404     5
 
 */
 
+// PRECISION: NewCounter 默认使用的精度
 var PRECISION = []int{1, 5, 60, 300, 3600, 18000, 86400}
 
-func (counter *Counter) GetCounterKey(slap int) string {
-	return fmt.Sprintf("count:%d:hits", slap)
+func (counter *Counter) GetCounterKey(slap int, name string) string {
+	return fmt.Sprintf("count:%d:%s", slap, name)
+}
+
+func (counter *Counter) getCountListMember(slap int, name string) string {
+	return fmt.Sprintf("%d:%s", slap, name)
 }
 
 func (counter *Counter) GetCountListKey() string {
 	return "countList"
 }
 
-// CounterIncr: 增加hit
-func (counter *Counter) CounterIncr(incr int64) error {
-	now := time.Now().Unix()
-	pipeline := counter.Client.Pipeline()
-	for _, slap := range PRECISION {
+// SetPrecisions: 配置计数器 name 记录哪些精度
+func (counter *Counter) SetPrecisions(name string, precisions []int) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if counter.precisions == nil {
+		counter.precisions = map[string][]int{}
+	}
+	counter.precisions[name] = append([]int{}, precisions...)
+}
+
+func (counter *Counter) getPrecisions(name string) []int {
+	counter.mu.RLock()
+	precisions, ok := counter.precisions[name]
+	counter.mu.RUnlock()
+	if ok {
+		return precisions
+	}
+	if len(counter.DefaultPrecision) == 0 {
+		return PRECISION
+	}
+	return counter.DefaultPrecision
+}
+
+// now: 未设置 Clock 时(比如直接构造 &Counter{Client: c})使用系统时间
+func (counter *Counter) now() time.Time {
+	if counter.Clock == nil {
+		return time.Now()
+	}
+	return counter.Clock.Now()
+}
+
+func (counter *Counter) incr(pipe redis.Pipeliner, name string, incr int64, now int64) {
+	finest := counter.finestPrecision(name)
+	for _, slap := range counter.getPrecisions(name) {
 		pnow := (int(now) / slap) * slap
 		pnowS := strconv.Itoa(pnow)
-		counter.Client.HIncrBy(context.Background(), counter.GetCounterKey(slap), pnowS, incr)
-		counter.Client.ZAdd(context.Background(), counter.GetCountListKey(), &redis.Z{
+		if !counter.Rollup {
+			pipe.HIncrBy(context.Background(), counter.GetCounterKey(slap, name), pnowS, incr)
+		} else if slap == finest {
+			pipe.HIncrBy(context.Background(), counter.GetCounterKey(slap, name), pnowS, incr)
+			pipe.ZAdd(context.Background(), counter.GetCountDirtyKey(name), &redis.Z{
+				Score:  float64(pnow),
+				Member: pnowS,
+			})
+		}
+		pipe.ZAdd(context.Background(), counter.GetCountListKey(), &redis.Z{
 			Score:  float64(slap),
-			Member: fmt.Sprintf("%d:hits", slap),
+			Member: counter.getCountListMember(slap, name),
 		})
 	}
+}
+
+// CounterIncr: 增加名为 name 的计数器, 比如页面, 接口, 错误码; 所有精度在一个事务中更新
+func (counter *Counter) CounterIncr(name string, incr int64) error {
+	return counter.CounterIncrAt(name, incr, counter.now())
+}
+
+// CounterIncrAt: 按事件发生的时间计数, 用于补录历史数据
+func (counter *Counter) CounterIncrAt(name string, incr int64, at time.Time) error {
+	pipeline := counter.Client.TxPipeline()
+	counter.incr(pipeline, name, incr, at.Unix())
+	_, err := pipeline.Exec(context.Background())
+	return err
+}
+
+// CounterIncrBatch: 一次事务增加多个计数器
+func (counter *Counter) CounterIncrBatch(incrs map[string]int64) error {
+	return counter.CounterIncrBatchAt(incrs, counter.now())
+}
+
+func (counter *Counter) CounterIncrBatchAt(incrs map[string]int64, at time.Time) error {
+	pipeline := counter.Client.TxPipeline()
+	for name, incr := range incrs {
+		counter.incr(pipeline, name, incr, at.Unix())
+	}
 	_, err := pipeline.Exec(context.Background())
 	return err
 }
+
+type Sample struct {
+	Time  time.Time
+	Value int64
+}
+
+type CounterInfo struct {
+	Name      string
+	Precision int
+}
+
+// GetCounter: 读取 [from, to] 内的数据, 按时间排序
+func (counter *Counter) GetCounter(name string, precision int, from, to time.Time) ([]*Sample, error) {
+	m, err := counter.Client.HGetAll(context.Background(), counter.GetCounterKey(precision, name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	samples := []*Sample{}
+	for k, v := range m {
+		ts, err := strconv.ParseInt(k, 10, 64)
+		if err != nil || ts < from.Unix() || ts > to.Unix() {
+			continue
+		}
+		value, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, &Sample{Time: time.Unix(ts, 0), Value: value})
+	}
+	if counter.Rollup && precision != counter.finestPrecision(name) {
+		if samples, err = counter.addPending(samples, name, precision, from, to); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	return samples, nil
+}
+
+// ListCounters: countList 中登记的所有计数器, 按精度排序
+func (counter *Counter) ListCounters() ([]*CounterInfo, error) {
+	members, err := counter.Client.ZRange(context.Background(), counter.GetCountListKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	infos := []*CounterInfo{}
+	for _, member := range members {
+		parts := strings.SplitN(member, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		precision, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		infos = append(infos, &CounterInfo{Name: parts[1], Precision: precision})
+	}
+	return infos, nil
+}
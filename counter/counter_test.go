@@ -0,0 +1,72 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	counter := NewCounter()
+	counter.Client.Del(context.Background(), counter.GetCounterKey(60, "hits"))
+	if err := counter.CounterIncr("hits", 3); err != nil {
+		t.Error(err)
+	}
+	now := time.Now()
+	samples, err := counter.GetCounter("hits", 60, now.Add(-time.Minute), now)
+	if err != nil || len(samples) != 1 || samples[0].Value != 3 {
+		t.Error("err:", err)
+	}
+	infos, err := counter.ListCounters()
+	if err != nil || len(infos) < len(PRECISION) {
+		t.Error("err:", err)
+	}
+}
+
+func TestCounter_CounterIncrBatch(t *testing.T) {
+	counter := NewCounter()
+	counter.SetPrecisions("batch:a", []int{60})
+	counter.Client.Del(context.Background(), counter.GetCounterKey(60, "batch:a"), counter.GetCounterKey(60, "batch:b"), counter.GetCounterKey(1, "batch:a"))
+	if err := counter.CounterIncrBatch(map[string]int64{"batch:a": 1, "batch:b": 2}); err != nil {
+		t.Error(err)
+	}
+	now := time.Now()
+	a, _ := counter.GetCounter("batch:a", 60, now.Add(-time.Minute), now)
+	b, _ := counter.GetCounter("batch:b", 60, now.Add(-time.Minute), now)
+	if len(a) != 1 || a[0].Value != 1 || len(b) != 1 || b[0].Value != 2 {
+		t.Error("err")
+	}
+	if counter.Client.Exists(context.Background(), counter.GetCounterKey(1, "batch:a")).Val() != 0 {
+		t.Error("err")
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestCounter_Clock(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{60})
+	at := time.Unix(1600000000, 0)
+	counter.Clock = fixedClock(at)
+	counter.Client.Del(context.Background(), counter.GetCounterKey(60, "clock"))
+	counter.CounterIncr("clock", 1)
+	counter.CounterIncrAt("clock", 2, at.Add(-time.Minute))
+	samples, err := counter.GetCounter("clock", 60, at.Add(-time.Hour), at)
+	if err != nil || len(samples) != 2 || samples[0].Value != 2 || samples[1].Value != 1 {
+		t.Error("err:", err)
+	}
+}
+
+func TestCounter_Literal(t *testing.T) {
+	counter := &Counter{Client: initRedisClient()}
+	counter.SetPrecisions("literal", []int{60})
+	if err := counter.CounterIncr("literal", 1); err != nil {
+		t.Error(err)
+	}
+	if len(counter.getPrecisions("other")) != len(PRECISION) {
+		t.Error("err")
+	}
+}
@@ -0,0 +1,162 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+hist:$windowSize:$name:$window  ----- hash  // 每个时间窗口内落在各个桶的观测次数, 按 DefaultRetention 过期
+	$upperBound   $count  // 桶上界, 超出所有桶时为 +Inf
+*/
+
+const DefaultHistogramWindow = 60
+
+var ErrNoBuckets = errors.New("histogram needs at least one bucket")
+
+type Histogram struct {
+	counter *Counter
+	Buckets []float64 // 升序的桶上界
+	Window  int       // 时间窗口, 秒
+}
+
+// LinearBuckets: start, start+width, ... 共 count 个桶
+func LinearBuckets(start, width float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+// ExponentialBuckets: start, start*factor, ... 共 count 个桶
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// NewHistogram: buckets 为空时返回 ErrNoBuckets
+func (counter *Counter) NewHistogram(buckets []float64) (*Histogram, error) {
+	if len(buckets) == 0 {
+		return nil, ErrNoBuckets
+	}
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{counter: counter, Buckets: sorted, Window: DefaultHistogramWindow}, nil
+}
+
+func formatBound(le float64) string {
+	return strconv.FormatFloat(le, 'g', -1, 64)
+}
+
+func (h *Histogram) GetHistogramKey(name string, window int64) string {
+	return fmt.Sprintf("hist:%d:%s:%d", h.Window, name, window)
+}
+
+func (h *Histogram) window(t time.Time) int64 {
+	return t.Unix() / int64(h.Window) * int64(h.Window)
+}
+
+// Observe: 记录一次观测值, 比如请求延迟
+func (h *Histogram) Observe(name string, value float64) error {
+	return h.ObserveAt(name, value, h.counter.now())
+}
+
+func (h *Histogram) ObserveAt(name string, value float64, at time.Time) error {
+	field := "+Inf"
+	if index := sort.SearchFloat64s(h.Buckets, value); index < len(h.Buckets) {
+		field = formatBound(h.Buckets[index])
+	}
+	window := h.window(at)
+	key := h.GetHistogramKey(name, window)
+	expireAt := window + int64(sampleCount(DefaultRetention, h.Window)*h.Window)
+	pipeline := h.counter.Client.TxPipeline()
+	pipeline.HIncrBy(context.Background(), key, field, 1)
+	pipeline.ExpireAt(context.Background(), key, time.Unix(expireAt, 0))
+	_, err := pipeline.Exec(context.Background())
+	return err
+}
+
+// Merge: 合并 [from, to] 内所有时间窗口, 返回每个桶的次数, 最后一个为 +Inf
+// 用别的桶布局写入的上界归入第一个不小于它的桶; 超出保留时间的部分已经过期, 不再查询
+func (h *Histogram) Merge(name string, from, to time.Time) ([]int64, error) {
+	now := h.counter.now()
+	if oldest := now.Add(-time.Duration(sampleCount(DefaultRetention, h.Window)*h.Window) * time.Second); from.Before(oldest) {
+		from = oldest
+	}
+	if to.After(now) {
+		to = now
+	}
+	pipeline := h.counter.Client.Pipeline()
+	cmds := []*redis.StringStringMapCmd{}
+	for window := h.window(from); window <= to.Unix(); window += int64(h.Window) {
+		cmds = append(cmds, pipeline.HGetAll(context.Background(), h.GetHistogramKey(name, window)))
+	}
+	counts := make([]int64, len(h.Buckets)+1)
+	if len(cmds) == 0 {
+		return counts, nil
+	}
+	if _, err := pipeline.Exec(context.Background()); err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		for k, v := range cmd.Val() {
+			le, err := strconv.ParseFloat(k, 64)
+			if err != nil {
+				continue
+			}
+			index := sort.SearchFloat64s(h.Buckets, le)
+			n, _ := strconv.ParseInt(v, 10, 64)
+			counts[index] += n
+		}
+	}
+	return counts, nil
+}
+
+// Quantile: [from, to] 内的 q 分位数, 在桶内线性插值; 落在 +Inf 桶时返回最大的桶上界
+func (h *Histogram) Quantile(name string, q float64, from, to time.Time) (float64, error) {
+	if q < 0 || q > 1 {
+		return 0, errors.New("quantile out of range")
+	}
+	counts, err := h.Merge(name, from, to)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0, errors.New("histogram is empty")
+	}
+	rank := q * float64(total)
+	var cumulative int64
+	for i, n := range counts {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		if i == len(h.Buckets) {
+			return h.Buckets[len(h.Buckets)-1], nil
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = h.Buckets[i-1]
+		} else if h.Buckets[0] < 0 {
+			return h.Buckets[0], nil
+		}
+		upper := h.Buckets[i]
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(n), nil
+	}
+	return h.Buckets[len(h.Buckets)-1], nil
+}
@@ -0,0 +1,48 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistogram_Quantile(t *testing.T) {
+	counter := NewCounter()
+	if _, err := counter.NewHistogram(nil); err != ErrNoBuckets {
+		t.Error("err:", err)
+	}
+	h, err := counter.NewHistogram(LinearBuckets(10, 10, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	counter.Client.Del(context.Background(), h.GetHistogramKey("latency", h.window(now)), h.GetHistogramKey("latency", h.window(now)-60))
+	for i := 1; i <= 50; i++ {
+		h.ObserveAt("latency", float64(i), now)
+		h.ObserveAt("latency", float64(i+50), now.Add(-time.Minute))
+	}
+	p50, err := h.Quantile("latency", 0.5, now.Add(-time.Minute), now)
+	if err != nil || p50 != 50 {
+		t.Error("err:", p50, err)
+	}
+	p99, err := h.Quantile("latency", 0.99, now.Add(-time.Minute), now)
+	if err != nil || p99 < 90 || p99 > 100 {
+		t.Error("err:", p99, err)
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	counter := NewCounter()
+	h, _ := counter.NewHistogram([]float64{1, 2, 4})
+	now := time.Now()
+	counter.Client.Del(context.Background(), h.GetHistogramKey("size", h.window(now)))
+	for _, v := range []float64{0.5, 2, 3, 8} {
+		h.ObserveAt("size", v, now)
+	}
+	// 换一种桶布局读取
+	other, _ := counter.NewHistogram([]float64{2, 4})
+	counts, err := other.Merge("size", now, now)
+	if err != nil || len(counts) != 3 || counts[0] != 2 || counts[1] != 1 || counts[2] != 1 {
+		t.Error("err:", counts, err)
+	}
+}
@@ -0,0 +1,86 @@
+package counter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+Prometheus text exposition format:
+# TYPE redis_counter_current gauge
+redis_counter_current{name="hits",precision="60"} 12
+# TYPE redis_counter_last gauge
+redis_counter_last{name="hits",precision="60"} 30
+# TYPE redis_counter_last_bucket_start_seconds gauge
+redis_counter_last_bucket_start_seconds{name="hits",precision="60"} 1600000000
+
+不带时间戳: 粗精度的上一个时间桶超过 1 小时, 带时间戳会被 Prometheus 当作过期样本丢弃
+*/
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler: 以 Prometheus 文本格式输出所有登记的计数器,
+// 包括当前时间桶的值, 以及最近一个完整时间桶的值
+func (counter *Counter) Handler() http.Handler {
+	return http.HandlerFunc(counter.servePrometheus)
+}
+
+func (counter *Counter) servePrometheus(w http.ResponseWriter, r *http.Request) {
+	infos, err := counter.ListCounters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := counter.now().Unix()
+	pipeline := counter.Client.Pipeline()
+	cmds := make([]*redis.SliceCmd, 0, len(infos))
+	for _, info := range infos {
+		current := now / int64(info.Precision) * int64(info.Precision)
+		last := current - int64(info.Precision)
+		cmds = append(cmds, pipeline.HMGet(context.Background(), counter.GetCounterKey(info.Precision, info.Name),
+			strconv.FormatInt(current, 10), strconv.FormatInt(last, 10)))
+	}
+	if _, err := pipeline.Exec(context.Background()); err != nil && err != redis.Nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var current, last, lastStart bytes.Buffer
+	current.WriteString("# HELP redis_counter_current Value of the current bucket of a redis counter.\n")
+	current.WriteString("# TYPE redis_counter_current gauge\n")
+	last.WriteString("# HELP redis_counter_last Value of the most recent complete bucket of a redis counter.\n")
+	last.WriteString("# TYPE redis_counter_last gauge\n")
+	lastStart.WriteString("# HELP redis_counter_last_bucket_start_seconds Start of the most recent complete bucket of a redis counter.\n")
+	lastStart.WriteString("# TYPE redis_counter_last_bucket_start_seconds gauge\n")
+	for i, info := range infos {
+		vals := cmds[i].Val()
+		currentTs := now / int64(info.Precision) * int64(info.Precision)
+		lastTs := currentTs - int64(info.Precision)
+		values := map[int64]int64{currentTs: toInt64(vals[0]), lastTs: toInt64(vals[1])}
+		// Rollup 模式下粗精度还要加上没有汇总的细粒度时间桶
+		if counter.Rollup && info.Precision != counter.finestPrecision(info.Name) {
+			pending, err := counter.addPending(nil, info.Name, info.Precision, time.Unix(lastTs, 0), time.Unix(currentTs, 0))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, sample := range pending {
+				values[sample.Time.Unix()] += sample.Value
+			}
+		}
+		labels := fmt.Sprintf(`{name="%s",precision="%d"}`, labelEscaper.Replace(info.Name), info.Precision)
+		fmt.Fprintf(&current, "redis_counter_current%s %d\n", labels, values[currentTs])
+		fmt.Fprintf(&last, "redis_counter_last%s %d\n", labels, values[lastTs])
+		fmt.Fprintf(&lastStart, "redis_counter_last_bucket_start_seconds%s %d\n", labels, lastTs)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(current.Bytes())
+	w.Write(last.Bytes())
+	w.Write(lastStart.Bytes())
+}
@@ -0,0 +1,50 @@
+package counter
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounter_Handler(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{60})
+	at := time.Unix(1600000020, 0)
+	counter.Clock = fixedClock(at)
+	counter.Client.Del(context.Background(), counter.GetCounterKey(60, "prom"))
+	counter.CounterIncr("prom", 3)
+	counter.CounterIncrAt("prom", 5, at.Add(-time.Minute))
+	rec := httptest.NewRecorder()
+	counter.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `redis_counter_current{name="prom",precision="60"} 3`) {
+		t.Error("err:", body)
+	}
+	if !strings.Contains(body, "redis_counter_last{name=\"prom\",precision=\"60\"} 5\n") {
+		t.Error("err:", body)
+	}
+	if !strings.Contains(body, `redis_counter_last_bucket_start_seconds{name="prom",precision="60"} 1599999960`) {
+		t.Error("err:", body)
+	}
+}
+
+func TestCounter_HandlerRollup(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{1, 60})
+	counter.Rollup = true
+	at := time.Unix(1600000020, 0)
+	counter.Clock = fixedClock(at)
+	counter.Client.Del(context.Background(), counter.GetCounterKey(1, "promRollup"), counter.GetCounterKey(60, "promRollup"),
+		counter.GetCountRolledKey(1, "promRollup"), counter.GetCountDirtyKey("promRollup"))
+	counter.CounterIncr("promRollup", 3)
+	counter.CounterIncrAt("promRollup", 5, at.Add(-time.Minute))
+	rec := httptest.NewRecorder()
+	counter.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `redis_counter_current{name="promRollup",precision="60"} 3`) {
+		t.Error("err:", body)
+	}
+	if !strings.Contains(body, "redis_counter_last{name=\"promRollup\",precision=\"60\"} 5\n") {
+		t.Error("err:", body)
+	}
+}
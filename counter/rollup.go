@@ -0,0 +1,169 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+Rollup 模式下 CounterIncr 只写最细的精度, 由 RunRollup 汇总到粗精度:
+countDirty:$name  ----- zset  // 有新写入、还没汇总的细粒度时间桶
+	$bucket   $bucket
+countRolled:$precision:$name  ----- hash  // 细粒度时间桶已经汇总过的值
+	$bucket   $value
+
+迟到的数据会让时间桶重新变脏, 下次汇总时只加上差值
+*/
+
+func (counter *Counter) GetCountDirtyKey(name string) string {
+	return "countDirty:" + name
+}
+
+func (counter *Counter) GetCountRolledKey(precision int, name string) string {
+	return fmt.Sprintf("countRolled:%d:%s", precision, name)
+}
+
+func (counter *Counter) finestPrecision(name string) int {
+	finest := 0
+	for _, precision := range counter.getPrecisions(name) {
+		if finest == 0 || precision < finest {
+			finest = precision
+		}
+	}
+	return finest
+}
+
+// KEYS: 细粒度 hash, 已汇总 hash, 脏桶 zset, 粗粒度 hash...
+// ARGV: 细粒度时间桶, 每个粗粒度 hash 对应的时间桶...
+var rollupScript = redis.NewScript(`
+local value = tonumber(redis.call("hget", KEYS[1], ARGV[1]) or "0")
+local rolled = tonumber(redis.call("hget", KEYS[2], ARGV[1]) or "0")
+local delta = value - rolled
+if delta ~= 0 then
+	for i = 4, #KEYS do
+		redis.call("hincrby", KEYS[i], ARGV[i-2], delta)
+	end
+	redis.call("hset", KEYS[2], ARGV[1], value)
+end
+redis.call("zrem", KEYS[3], ARGV[1])
+return delta
+`)
+
+// rollupCounter: 把 name 已经结束的细粒度时间桶汇总到粗精度
+func (counter *Counter) rollupCounter(name string) error {
+	finest := counter.finestPrecision(name)
+	cutoff := counter.now().Unix() - int64(finest)
+	buckets, err := counter.Client.ZRangeByScore(context.Background(), counter.GetCountDirtyKey(name), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(cutoff, 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		ts, err := strconv.ParseInt(bucket, 10, 64)
+		if err != nil {
+			continue
+		}
+		keys := []string{counter.GetCounterKey(finest, name), counter.GetCountRolledKey(finest, name), counter.GetCountDirtyKey(name)}
+		args := []interface{}{bucket}
+		for _, precision := range counter.getPrecisions(name) {
+			if precision == finest {
+				continue
+			}
+			keys = append(keys, counter.GetCounterKey(precision, name))
+			args = append(args, ts/int64(precision)*int64(precision))
+		}
+		if err := rollupScript.Run(context.Background(), counter.Client, keys, args...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollupCounters: 汇总一遍所有登记的计数器
+func (counter *Counter) RollupCounters() error {
+	infos, err := counter.ListCounters()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, info := range infos {
+		if names[info.Name] {
+			continue
+		}
+		names[info.Name] = true
+		if err := counter.rollupCounter(info.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRollup: 每 interval 汇总一次, 直到 ctx 结束
+func (counter *Counter) RunRollup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			counter.RollupCounters()
+		}
+	}
+}
+
+// addPending: 查询粗精度时, 加上还没汇总的细粒度时间桶
+func (counter *Counter) addPending(samples []*Sample, name string, precision int, from, to time.Time) ([]*Sample, error) {
+	finest := counter.finestPrecision(name)
+	buckets, err := counter.Client.ZRangeByScore(context.Background(), counter.GetCountDirtyKey(name), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix()-int64(precision), 10),
+		Max: strconv.FormatInt(to.Unix()+int64(precision), 10),
+	}).Result()
+	if err != nil || len(buckets) == 0 {
+		return samples, err
+	}
+	pipeline := counter.Client.Pipeline()
+	valueCmd := pipeline.HMGet(context.Background(), counter.GetCounterKey(finest, name), buckets...)
+	rolledCmd := pipeline.HMGet(context.Background(), counter.GetCountRolledKey(finest, name), buckets...)
+	if _, err := pipeline.Exec(context.Background()); err != nil {
+		return nil, err
+	}
+	byTime := map[int64]*Sample{}
+	for _, sample := range samples {
+		byTime[sample.Time.Unix()] = sample
+	}
+	for i, bucket := range buckets {
+		ts, err := strconv.ParseInt(bucket, 10, 64)
+		if err != nil {
+			continue
+		}
+		coarse := ts / int64(precision) * int64(precision)
+		if coarse < from.Unix() || coarse > to.Unix() {
+			continue
+		}
+		delta := toInt64(valueCmd.Val()[i]) - toInt64(rolledCmd.Val()[i])
+		if delta == 0 {
+			continue
+		}
+		sample, ok := byTime[coarse]
+		if !ok {
+			sample = &Sample{Time: time.Unix(coarse, 0)}
+			byTime[coarse] = sample
+			samples = append(samples, sample)
+		}
+		sample.Value += delta
+	}
+	return samples, nil
+}
+
+func toInt64(val interface{}) int64 {
+	s, _ := val.(string)
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,35 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCounter_Rollup(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{1, 60})
+	counter.Rollup = true
+	at := time.Unix(1600000020, 0)
+	counter.Clock = fixedClock(at)
+	counter.Client.Del(context.Background(), counter.GetCounterKey(1, "rollup"), counter.GetCounterKey(60, "rollup"),
+		counter.GetCountRolledKey(1, "rollup"), counter.GetCountDirtyKey("rollup"))
+	counter.CounterIncrAt("rollup", 2, at.Add(-10*time.Second))
+	counter.CounterIncr("rollup", 3)
+	if counter.Client.Exists(context.Background(), counter.GetCounterKey(60, "rollup")).Val() != 0 {
+		t.Error("err")
+	}
+	if err := counter.RollupCounters(); err != nil {
+		t.Error(err)
+	}
+	// 迟到的数据
+	counter.CounterIncrAt("rollup", 4, at.Add(-10*time.Second))
+	samples, err := counter.GetCounter("rollup", 60, at.Add(-2*time.Minute), at)
+	if err != nil || len(samples) != 2 || samples[0].Value != 6 || samples[1].Value != 3 {
+		t.Error("err:", err)
+	}
+	counter.RollupCounters()
+	samples, err = counter.GetCounter("rollup", 60, at.Add(-2*time.Minute), at)
+	if err != nil || len(samples) != 2 || samples[0].Value != 6 || samples[1].Value != 3 {
+		t.Error("err:", err)
+	}
+}
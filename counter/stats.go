@@ -0,0 +1,94 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+stats:$name:$bucket  ----- hash  // 每个时间桶的统计, 过期后自动滚动
+	min:
+	max:
+	count:
+	sum:
+	sumsq:
+*/
+
+const (
+	StatsPrecision = 3600
+	StatsRetention = 2 * StatsPrecision
+)
+
+type Stats struct {
+	Min    float64
+	Max    float64
+	Count  int64
+	Sum    float64
+	SumSq  float64
+	Mean   float64
+	StdDev float64
+}
+
+func (counter *Counter) GetStatsKey(name string, bucket int64) string {
+	return fmt.Sprintf("stats:%s:%d", name, bucket)
+}
+
+func statsBucket(t time.Time) int64 {
+	return t.Unix() / StatsPrecision * StatsPrecision
+}
+
+var updateStatsScript = redis.NewScript(`
+local value = tonumber(ARGV[1])
+local min = tonumber(redis.call("hget", KEYS[1], "min"))
+if not min or value < min then
+	redis.call("hset", KEYS[1], "min", ARGV[1])
+end
+local max = tonumber(redis.call("hget", KEYS[1], "max"))
+if not max or value > max then
+	redis.call("hset", KEYS[1], "max", ARGV[1])
+end
+redis.call("hincrby", KEYS[1], "count", 1)
+redis.call("hincrbyfloat", KEYS[1], "sum", ARGV[1])
+redis.call("hincrbyfloat", KEYS[1], "sumsq", value * value)
+redis.call("expire", KEYS[1], ARGV[2])
+return 1
+`)
+
+// UpdateStats: 记录一次观测值, 比如延迟, 包大小
+func (counter *Counter) UpdateStats(name string, value float64) error {
+	return counter.UpdateStatsAt(name, value, counter.now())
+}
+
+func (counter *Counter) UpdateStatsAt(name string, value float64, at time.Time) error {
+	key := counter.GetStatsKey(name, statsBucket(at))
+	return updateStatsScript.Run(context.Background(), counter.Client, []string{key},
+		strconv.FormatFloat(value, 'f', -1, 64), StatsRetention).Err()
+}
+
+// GetStats: 读取 at 所在时间桶的统计
+func (counter *Counter) GetStats(name string, at time.Time) (*Stats, error) {
+	m, err := counter.Client.HGetAll(context.Background(), counter.GetStatsKey(name, statsBucket(at))).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{}
+	stats.Min, _ = strconv.ParseFloat(m["min"], 64)
+	stats.Max, _ = strconv.ParseFloat(m["max"], 64)
+	stats.Count, _ = strconv.ParseInt(m["count"], 10, 64)
+	stats.Sum, _ = strconv.ParseFloat(m["sum"], 64)
+	stats.SumSq, _ = strconv.ParseFloat(m["sumsq"], 64)
+	if stats.Count > 0 {
+		stats.Mean = stats.Sum / float64(stats.Count)
+	}
+	if stats.Count > 1 {
+		n := float64(stats.Count)
+		variance := (stats.SumSq - stats.Sum*stats.Sum/n) / (n - 1)
+		stats.StdDev = math.Sqrt(math.Max(variance, 0))
+	}
+	return stats, nil
+}
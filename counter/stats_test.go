@@ -0,0 +1,25 @@
+package counter
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounter_UpdateStats(t *testing.T) {
+	counter := NewCounter()
+	counter.Client.Del(context.Background(), counter.GetStatsKey("latency", statsBucket(time.Now())))
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		if err := counter.UpdateStats("latency", v); err != nil {
+			t.Error(err)
+		}
+	}
+	stats, err := counter.GetStats("latency", time.Now())
+	if err != nil || stats.Min != 2 || stats.Max != 9 || stats.Count != 8 || stats.Mean != 5 {
+		t.Error("err:", err)
+	}
+	if math.Abs(stats.StdDev-math.Sqrt(32.0/7)) > 1e-9 {
+		t.Error("err:", stats.StdDev)
+	}
+}
@@ -0,0 +1,73 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/*
+unique:$precision:$name:$bucket  ----- hyperloglog  // 去重计数, 按 DefaultRetention 过期
+*/
+
+func (counter *Counter) GetUniqueKey(precision int, name string, bucket int64) string {
+	return fmt.Sprintf("unique:%d:%s:%d", precision, name, bucket)
+}
+
+// UniqueAdd: 记录访问者 ids, 比如 uid, ip
+func (counter *Counter) UniqueAdd(name string, ids ...string) error {
+	return counter.UniqueAddAt(name, counter.now(), ids...)
+}
+
+func (counter *Counter) UniqueAddAt(name string, at time.Time, ids ...string) error {
+	members := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, id)
+	}
+	pipeline := counter.Client.TxPipeline()
+	for _, precision := range counter.getPrecisions(name) {
+		bucket := at.Unix() / int64(precision) * int64(precision)
+		key := counter.GetUniqueKey(precision, name, bucket)
+		expireAt := bucket + int64(sampleCount(DefaultRetention, precision)*precision)
+		pipeline.PFAdd(context.Background(), key, members...)
+		pipeline.ExpireAt(context.Background(), key, time.Unix(expireAt, 0))
+	}
+	_, err := pipeline.Exec(context.Background())
+	return err
+}
+
+// UniqueCount: at 所在时间桶的去重数(估算值)
+func (counter *Counter) UniqueCount(name string, precision int, at time.Time) (int64, error) {
+	bucket := at.Unix() / int64(precision) * int64(precision)
+	return counter.Client.PFCount(context.Background(), counter.GetUniqueKey(precision, name, bucket)).Result()
+}
+
+// UniqueCountRange: 合并 [from, to] 内的时间桶后的去重数(估算值)
+// 范围会被限制在 precision 的保留期内, 超出的时间桶已经过期, 也避免 PFMERGE 的 key 过多
+func (counter *Counter) UniqueCountRange(name string, precision int, from, to time.Time) (int64, error) {
+	now := counter.now()
+	if oldest := now.Add(-time.Duration(sampleCount(DefaultRetention, precision)*precision) * time.Second); from.Before(oldest) {
+		from = oldest
+	}
+	if to.After(now) {
+		to = now
+	}
+	keys := []string{}
+	for bucket := from.Unix() / int64(precision) * int64(precision); bucket <= to.Unix(); bucket += int64(precision) {
+		keys = append(keys, counter.GetUniqueKey(precision, name, bucket))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	mergeKey := "unique:merge:" + uuid.NewString()
+	pipeline := counter.Client.TxPipeline()
+	pipeline.PFMerge(context.Background(), mergeKey, keys...)
+	countCmd := pipeline.PFCount(context.Background(), mergeKey)
+	pipeline.Del(context.Background(), mergeKey)
+	if _, err := pipeline.Exec(context.Background()); err != nil {
+		return 0, err
+	}
+	return countCmd.Val(), nil
+}
@@ -0,0 +1,23 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCounter_UniqueCount(t *testing.T) {
+	counter := NewCounterWithPrecision([]int{60})
+	at := time.Now().Unix() / 60 * 60
+	counter.Client.Del(context.Background(), counter.GetUniqueKey(60, "uv", at), counter.GetUniqueKey(60, "uv", at-60))
+	counter.UniqueAddAt("uv", time.Unix(at, 0), "a", "b", "a")
+	counter.UniqueAddAt("uv", time.Unix(at-60, 0), "b", "c")
+	n, err := counter.UniqueCount("uv", 60, time.Unix(at, 0))
+	if err != nil || n != 2 {
+		t.Error("err:", err)
+	}
+	n, err = counter.UniqueCountRange("uv", 60, time.Unix(at-60, 0), time.Unix(at, 0))
+	if err != nil || n != 3 {
+		t.Error("err:", err)
+	}
+}
@@ -0,0 +1,167 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+lockOwner:$key  ----- hash  // 锁持有者信息, 与锁同时过期
+	owner:     $id
+	hostname:  $hostname
+	pid:       $pid
+	acquired:  $unixMilli
+	purpose:   $purpose
+
+lockAudit  ----- list  // 强制解锁的审计记录, 保留最近 1000 条
+*/
+
+const LockAuditLimit = 1000
+
+var ErrLockNotHeld = errors.New("lock not held")
+
+var hostname, _ = os.Hostname()
+
+func GetLockOwnerKey(key string) string {
+	return "lockOwner:" + key
+}
+
+func GetLockAuditKey() string {
+	return "lockAudit"
+}
+
+func ownerArgs(purpose string) []interface{} {
+	return []interface{}{hostname, os.Getpid(), time.Now().UnixNano() / int64(time.Millisecond), purpose}
+}
+
+type LockInfo struct {
+	Key        string
+	Owner      string
+	Hostname   string
+	PID        int
+	AcquiredAt time.Time
+	Purpose    string
+	TTL        time.Duration
+}
+
+type LockAudit struct {
+	Key      string    `json:"key"`
+	Owner    string    `json:"owner"`
+	Hostname string    `json:"hostname"`
+	PID      int       `json:"pid"`
+	Operator string    `json:"operator"`
+	Reason   string    `json:"reason"`
+	Time     time.Time `json:"time"`
+}
+
+func (info *LockInfo) fromHash(m map[string]string) {
+	info.Hostname = m["hostname"]
+	info.Purpose = m["purpose"]
+	info.PID, _ = strconv.Atoi(m["pid"])
+	if acquired, err := strconv.ParseInt(m["acquired"], 10, 64); err == nil {
+		info.AcquiredAt = time.Unix(0, acquired*int64(time.Millisecond))
+	}
+}
+
+// GetLockInfo: 查看锁的持有者, 锁不存在时返回 ErrLockNotHeld
+func (locker *Locker) GetLockInfo(key string) (*LockInfo, error) {
+	ctx := context.Background()
+	pipe := locker.Client.Pipeline()
+	ownerCmd := pipe.Get(ctx, GetLockKey(key))
+	ttlCmd := pipe.PTTL(ctx, GetLockKey(key))
+	infoCmd := pipe.HGetAll(ctx, GetLockOwnerKey(key))
+	pipe.Exec(ctx)
+	if ownerCmd.Err() == redis.Nil {
+		return nil, ErrLockNotHeld
+	}
+	if ownerCmd.Err() != nil {
+		return nil, ownerCmd.Err()
+	}
+	info := &LockInfo{Key: key, Owner: ownerCmd.Val(), TTL: ttlCmd.Val()}
+	info.fromHash(infoCmd.Val())
+	return info, nil
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// ListLocks: 列出以 prefix 开头的所有锁, prefix 中的 glob 字符按字面匹配
+func (locker *Locker) ListLocks(prefix string) ([]*LockInfo, error) {
+	infos := []*LockInfo{}
+	iter := locker.Client.Scan(context.Background(), 0, globEscaper.Replace(GetLockKey(prefix))+"*", 100).Iterator()
+	for iter.Next(context.Background()) {
+		info, err := locker.GetLockInfo(strings.TrimPrefix(iter.Val(), GetLockKey("")))
+		if err == ErrLockNotHeld {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, iter.Err()
+}
+
+// ForceReleaseLock: 管理员强制解锁, 并记录审计日志
+func (locker *Locker) ForceReleaseLock(key, operator, reason string) error {
+	txf := func(tx *redis.Tx) error {
+		ownerCmd := tx.Get(context.Background(), GetLockKey(key))
+		if ownerCmd.Err() == redis.Nil {
+			return ErrLockNotHeld
+		}
+		if ownerCmd.Err() != nil {
+			return ownerCmd.Err()
+		}
+		info := &LockInfo{Key: key, Owner: ownerCmd.Val()}
+		info.fromHash(tx.HGetAll(context.Background(), GetLockOwnerKey(key)).Val())
+		audit, err := json.Marshal(&LockAudit{
+			Key:      key,
+			Owner:    info.Owner,
+			Hostname: info.Hostname,
+			PID:      info.PID,
+			Operator: operator,
+			Reason:   reason,
+			Time:     time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		pipe := tx.TxPipeline()
+		pipe.Del(context.Background(), GetLockKey(key), GetLockOwnerKey(key))
+		pipe.Publish(context.Background(), GetLockChannel(key), info.Owner)
+		pipe.LPush(context.Background(), GetLockAuditKey(), audit)
+		pipe.LTrim(context.Background(), GetLockAuditKey(), 0, LockAuditLimit-1)
+		_, err = pipe.Exec(context.Background())
+		return err
+	}
+	for {
+		err := locker.Client.Watch(context.Background(), txf, GetLockKey(key))
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return err
+	}
+}
+
+// GetLockAudits: 最近的强制解锁记录
+func (locker *Locker) GetLockAudits(count int64) ([]*LockAudit, error) {
+	vals, err := locker.Client.LRange(context.Background(), GetLockAuditKey(), 0, count-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	audits := []*LockAudit{}
+	for _, val := range vals {
+		audit := &LockAudit{}
+		if err := json.Unmarshal([]byte(val), audit); err != nil {
+			return nil, err
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
@@ -0,0 +1,40 @@
+package lock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocker_ForceReleaseLock(t *testing.T) {
+	locker := NewLocker()
+	_, isLock := locker.AcquireLockFor("admin:hello", "test", 10*time.Second, 2*time.Second)
+	if isLock == false {
+		t.Error("err")
+	}
+	infos, err := locker.ListLocks("admin:")
+	if err != nil || len(infos) != 1 || infos[0].Purpose != "test" || infos[0].TTL <= 0 {
+		t.Error("err:", err)
+	}
+	if err := locker.ForceReleaseLock("admin:hello", "hwgao", "hang"); err != nil {
+		t.Error(err)
+	}
+	if _, err := locker.GetLockInfo("admin:hello"); err != ErrLockNotHeld {
+		t.Error("err")
+	}
+	audits, err := locker.GetLockAudits(1)
+	if err != nil || len(audits) != 1 || audits[0].Operator != "hwgao" {
+		t.Error("err:", err)
+	}
+}
+
+func TestLocker_ListLocksGlob(t *testing.T) {
+	locker := NewLocker()
+	id1, _ := locker.AcquireLock("glob*:a", 10*time.Second, 2*time.Second)
+	id2, _ := locker.AcquireLock("globx:b", 10*time.Second, 2*time.Second)
+	defer locker.ReleaseLock("glob*:a", id1)
+	defer locker.ReleaseLock("globx:b", id2)
+	infos, err := locker.ListLocks("glob*")
+	if err != nil || len(infos) != 1 || infos[0].Key != "glob*:a" {
+		t.Error("err:", err)
+	}
+}
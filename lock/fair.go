@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+/*
+公平锁:
+lockQueue:$key  ----- zset  // 等待队列, 按票号排序
+	$id   $ticket
+lockQueueTimeout:$key  ----- zset  // 等待者的过期时间, 等待者需要不断续期
+	$id   $deadline
+lockQueueTicket:$key  ----- int  // 票号
+*/
+
+const (
+	FairLockHeartbeat     = time.Second
+	FairLockWaiterTimeout = 3 * FairLockHeartbeat
+)
+
+func GetLockQueueKey(key string) string {
+	return "lockQueue:" + key
+}
+
+func GetLockQueueTimeoutKey(key string) string {
+	return "lockQueueTimeout:" + key
+}
+
+func GetLockQueueTicketKey(key string) string {
+	return "lockQueueTicket:" + key
+}
+
+// 清理过期的等待者, 入队(或续期), 只有队首且锁空闲时才加锁
+// 过期时间使用 redis 服务端的 TIME, 避免客户端时钟偏差把别人的等待者清理掉
+// ARGV: id, 等待者超时, 锁超时, hostname, pid, acquired, purpose
+var acquireFairScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local expired = redis.call("zrangebyscore", KEYS[3], "-inf", now)
+for _, member in ipairs(expired) do
+	redis.call("zrem", KEYS[2], member)
+	redis.call("zrem", KEYS[3], member)
+end
+if not redis.call("zscore", KEYS[2], ARGV[1]) then
+	redis.call("zadd", KEYS[2], redis.call("incr", KEYS[4]), ARGV[1])
+end
+redis.call("zadd", KEYS[3], now + tonumber(ARGV[2]), ARGV[1])
+for i = 2, 4 do
+	redis.call("pexpire", KEYS[i], ARGV[2])
+end
+local head = redis.call("zrange", KEYS[2], 0, 0)[1]
+if head == ARGV[1] and redis.call("exists", KEYS[1]) == 0 then
+	redis.call("set", KEYS[1], ARGV[1], "PX", ARGV[3])
+	redis.call("hset", KEYS[5], "owner", ARGV[1], "hostname", ARGV[4], "pid", ARGV[5], "acquired", ARGV[6], "purpose", ARGV[7])
+	redis.call("pexpire", KEYS[5], ARGV[3])
+	redis.call("zrem", KEYS[2], ARGV[1])
+	redis.call("zrem", KEYS[3], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// AcquireFairLock: 按到达顺序加锁, 使用 ReleaseLock 释放
+func (locker *Locker) AcquireFairLock(key string, lockTimeout, timeout time.Duration) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	id := uuid.NewString()
+	keys := []string{GetLockKey(key), GetLockQueueKey(key), GetLockQueueTimeoutKey(key), GetLockQueueTicketKey(key), GetLockOwnerKey(key)}
+	try := func() bool {
+		args := append([]interface{}{id, FairLockWaiterTimeout.Milliseconds(), lockTimeout.Milliseconds()}, ownerArgs("")...)
+		ok, err := acquireFairScript.Run(context.Background(), locker.Client, keys, args...).Int()
+		return err == nil && ok == 1
+	}
+	// 锁空闲但自己不是队首时, 至少每个心跳周期续期一次
+	next := func() time.Duration {
+		wait := locker.Client.PTTL(context.Background(), GetLockKey(key)).Val()
+		if wait <= 0 || wait > FairLockHeartbeat {
+			wait = FairLockHeartbeat
+		}
+		return wait
+	}
+	if locker.waitRelease(ctx, try, next, key) {
+		return id, true
+	}
+	// 放弃等待, 出队并唤醒后面的等待者
+	pipe := locker.Client.TxPipeline()
+	pipe.ZRem(context.Background(), GetLockQueueKey(key), id)
+	pipe.ZRem(context.Background(), GetLockQueueTimeoutKey(key), id)
+	pipe.Publish(context.Background(), GetLockChannel(key), id)
+	pipe.Exec(context.Background())
+	return "", false
+}
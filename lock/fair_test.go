@@ -0,0 +1,40 @@
+package lock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocker_AcquireFairLock(t *testing.T) {
+	locker := NewLocker()
+	id, isLock := locker.AcquireFairLock("fair", 10*time.Second, 2*time.Second)
+	if isLock == false {
+		t.Error("err")
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	order := []int{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			idI, isLockI := locker.AcquireFairLock("fair", 10*time.Second, 5*time.Second)
+			if isLockI == false {
+				t.Error("err")
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			locker.ReleaseLock("fair", idI)
+		}(i)
+		// 保证按顺序入队
+		time.Sleep(100 * time.Millisecond)
+	}
+	locker.ReleaseLock("fair", id)
+	wg.Wait()
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Error("err:", order)
+	}
+}
@@ -30,24 +30,157 @@ func GetLockKey(key string) string {
 	return "lock:" + key
 }
 
-func (locker *Locker) AcquireLock(key string, lockTimeout, timeout time.Duration) (string, bool) {
-	endTime := time.Now().Add(timeout)
+func GetLockChannel(key string) string {
+	return "lockRelease:" + key
+}
+
+func GetFenceKey(key string) string {
+	return "fence:" + key
+}
+
+func GetFenceSeenKey(key string) string {
+	return "fenceSeen:" + key
+}
+
+var ErrStaleToken = errors.New("stale fencing token")
+
+// 加锁并记录持有者信息; fencing 时同时 INCR fence key 返回 token; 失败返回 0
+var acquireScript = redis.NewScript(`
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	redis.call("hset", KEYS[2], "owner", ARGV[1], "hostname", ARGV[3], "pid", ARGV[4], "acquired", ARGV[5], "purpose", ARGV[6])
+	redis.call("pexpire", KEYS[2], ARGV[2])
+	if ARGV[7] == "1" then
+		return redis.call("incr", KEYS[3])
+	end
+	return 1
+end
+if redis.call("pttl", KEYS[1]) == -1 then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// WaitNotify: 订阅 channels, 反复 try 直到成功、出错或 ctx 结束
+// 发布方崩溃时不会有通知, 每次最多等待 next() 后重试; 信号量也用它等待释放
+func WaitNotify(ctx context.Context, client *redis.Client, channels []string, try func() (bool, error), next func() time.Duration) error {
+	if ok, err := try(); ok || err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	sub := client.Subscribe(ctx, channels...)
+	defer sub.Close()
+	// 确认订阅成功后再重试, 避免错过通知
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		if ok, err := try(); ok || err != nil {
+			return err
+		}
+		timer := time.NewTimer(next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-ch:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// waitRelease: 订阅锁的释放通知, 持有者崩溃时最多等到 next() 后重试
+func (locker *Locker) waitRelease(ctx context.Context, try func() bool, next func() time.Duration, keys ...string) bool {
+	channels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		channels = append(channels, GetLockChannel(key))
+	}
+	return WaitNotify(ctx, locker.Client, channels, func() (bool, error) { return try(), nil }, next) == nil
+}
+
+// lockWait: 锁中最短的剩余时间, 锁不存在时短暂等待后重试
+func (locker *Locker) lockWait(keys ...string) time.Duration {
+	wait := time.Duration(0)
+	for _, key := range keys {
+		ttl := locker.Client.PTTL(context.Background(), GetLockKey(key)).Val()
+		if ttl > 0 && (wait == 0 || ttl < wait) {
+			wait = ttl
+		}
+	}
+	if wait <= 0 {
+		wait = 10 * time.Millisecond
+	}
+	return wait
+}
+
+func (locker *Locker) acquire(ctx context.Context, key, purpose string, lockTimeout time.Duration, fencing bool) (string, int64, bool) {
 	id := uuid.NewString()
-	for time.Now().Before(endTime) {
-		ctx := context.Background()
-		cmd := locker.Client.SetNX(ctx, GetLockKey(key), id, -1)
-		if cmd.Err() != nil || cmd.Val() == false {
-			cmdT := locker.Client.TTL(ctx, GetLockKey(key))
-			if cmdT.Val() == -1 {
-				locker.Client.Expire(ctx, GetLockKey(key), lockTimeout)
-			}
-		} else {
-			locker.Client.Expire(ctx, GetLockKey(key), lockTimeout)
-			return id, true
+	keys := []string{GetLockKey(key), GetLockOwnerKey(key), GetFenceKey(key)}
+	fencingArg := "0"
+	if fencing {
+		fencingArg = "1"
+	}
+	var token int64
+	try := func() bool {
+		var err error
+		args := append([]interface{}{id, lockTimeout.Milliseconds()}, ownerArgs(purpose)...)
+		token, err = acquireScript.Run(context.Background(), locker.Client, keys, append(args, fencingArg)...).Int64()
+		return err == nil && token > 0
+	}
+	if locker.waitRelease(ctx, try, func() time.Duration { return locker.lockWait(key) }, key) {
+		return id, token, true
+	}
+	return "", 0, false
+}
+
+func (locker *Locker) AcquireLock(key string, lockTimeout, timeout time.Duration) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	id, _, ok := locker.acquire(ctx, key, "", lockTimeout, false)
+	return id, ok
+}
+
+// AcquireLockFor: 加锁并记录用途, 便于排查
+func (locker *Locker) AcquireLockFor(key, purpose string, lockTimeout, timeout time.Duration) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	id, _, ok := locker.acquire(ctx, key, purpose, lockTimeout, false)
+	return id, ok
+}
+
+// AcquireLockWithToken: 加锁并返回单调递增的 fencing token
+func (locker *Locker) AcquireLockWithToken(key string, lockTimeout, timeout time.Duration) (string, int64, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return locker.acquire(ctx, key, "", lockTimeout, true)
+}
+
+// FencedWrite: 带 token 的写操作, token 小于已见过的最大 token 时拒绝
+func (locker *Locker) FencedWrite(key string, token int64, write func(pipe redis.Pipeliner)) error {
+	txf := func(tx *redis.Tx) error {
+		seen, err := tx.Get(context.Background(), GetFenceSeenKey(key)).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if token < seen {
+			return ErrStaleToken
+		}
+		pipe := tx.TxPipeline()
+		pipe.Set(context.Background(), GetFenceSeenKey(key), token, -1)
+		write(pipe)
+		_, err = pipe.Exec(context.Background())
+		return err
+	}
+	for {
+		err := locker.Client.Watch(context.Background(), txf, GetFenceSeenKey(key))
+		if err == redis.TxFailedErr {
+			continue
 		}
-		time.Sleep(time.Millisecond * 10)
+		return err
 	}
-	return "", false
 }
 
 func (locker *Locker) ReleaseLock(key string, identifier string) error {
@@ -58,7 +191,8 @@ func (locker *Locker) ReleaseLock(key string, identifier string) error {
 		}
 		if cmd.Val() == identifier {
 			pipe := tx.TxPipeline()
-			pipe.Del(context.Background(), GetLockKey(key))
+			pipe.Del(context.Background(), GetLockKey(key), GetLockOwnerKey(key))
+			pipe.Publish(context.Background(), GetLockChannel(key), identifier)
 			_, err := pipe.Exec(context.Background())
 			return err
 		} else {
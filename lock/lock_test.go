@@ -1,8 +1,11 @@
 package lock
 
 import (
+	"context"
 	"testing"
 	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
 func TestLocker(t *testing.T) {
@@ -21,3 +24,44 @@ func TestLocker(t *testing.T) {
 		t.Error("err")
 	}
 }
+
+func TestLocker_FencedWrite(t *testing.T) {
+	locker := NewLocker()
+	id1, token1, isLock1 := locker.AcquireLockWithToken("fence", 10*time.Second, 2*time.Second)
+	if isLock1 == false {
+		t.Error("err")
+	}
+	locker.ReleaseLock("fence", id1)
+	id2, token2, isLock2 := locker.AcquireLockWithToken("fence", 10*time.Second, 2*time.Second)
+	if isLock2 == false || token2 <= token1 {
+		t.Error("err")
+	}
+	defer locker.ReleaseLock("fence", id2)
+	write := func(pipe redis.Pipeliner) {
+		pipe.Set(context.Background(), "fence:data", "hello", -1)
+	}
+	if err := locker.FencedWrite("fence", token2, write); err != nil {
+		t.Error(err)
+	}
+	if err := locker.FencedWrite("fence", token1, write); err != ErrStaleToken {
+		t.Error("err")
+	}
+}
+
+func TestLocker_WaitRelease(t *testing.T) {
+	locker := NewLocker()
+	id1, isLock1 := locker.AcquireLock("wait", 10*time.Second, 2*time.Second)
+	if isLock1 == false {
+		t.Error("err")
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		locker.ReleaseLock("wait", id1)
+	}()
+	start := time.Now()
+	id2, isLock2 := locker.AcquireLock("wait", 10*time.Second, 5*time.Second)
+	if isLock2 == false || time.Since(start) > time.Second {
+		t.Error("err")
+	}
+	locker.ReleaseLock("wait", id2)
+}
@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// KEYS 为 (锁, 持有者信息) 对; 任意一个锁被占用则什么都不做, 否则全部加锁
+var acquireMultiScript = redis.NewScript(`
+for i = 1, #KEYS, 2 do
+	if redis.call("exists", KEYS[i]) == 1 then
+		return 0
+	end
+end
+for i = 1, #KEYS, 2 do
+	redis.call("set", KEYS[i], ARGV[1], "PX", ARGV[2])
+	redis.call("hset", KEYS[i+1], "owner", ARGV[1], "hostname", ARGV[3], "pid", ARGV[4], "acquired", ARGV[5], "purpose", ARGV[6])
+	redis.call("pexpire", KEYS[i+1], ARGV[2])
+end
+return 1
+`)
+
+// 释放自己持有的锁并发布通知, ARGV[2:] 为对应的 channel
+var releaseMultiScript = redis.NewScript(`
+local n = 0
+for i = 1, #KEYS, 2 do
+	if redis.call("get", KEYS[i]) == ARGV[1] then
+		redis.call("del", KEYS[i], KEYS[i+1])
+		redis.call("publish", ARGV[(i+1)/2+1], ARGV[1])
+		n = n + 1
+	end
+end
+return n
+`)
+
+// sortKeys: 去重并排序, 保证加锁顺序确定
+func sortKeys(keys []string) []string {
+	set := map[string]bool{}
+	sorted := []string{}
+	for _, key := range keys {
+		if !set[key] {
+			set[key] = true
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func multiLockKeys(keys []string) []string {
+	redisKeys := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		redisKeys = append(redisKeys, GetLockKey(key), GetLockOwnerKey(key))
+	}
+	return redisKeys
+}
+
+// AcquireMultiLock: 原子地同时获取多个锁, 要么全部成功要么都不加
+func (locker *Locker) AcquireMultiLock(keys []string, lockTimeout, timeout time.Duration) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	keys = sortKeys(keys)
+	id := uuid.NewString()
+	redisKeys := multiLockKeys(keys)
+	try := func() bool {
+		args := append([]interface{}{id, lockTimeout.Milliseconds()}, ownerArgs("")...)
+		ok, err := acquireMultiScript.Run(context.Background(), locker.Client, redisKeys, args...).Int()
+		return err == nil && ok == 1
+	}
+	if locker.waitRelease(ctx, try, func() time.Duration { return locker.lockWait(keys...) }, keys...) {
+		return id, true
+	}
+	return "", false
+}
+
+func (locker *Locker) ReleaseMultiLock(keys []string, identifier string) error {
+	keys = sortKeys(keys)
+	args := []interface{}{identifier}
+	for _, key := range keys {
+		args = append(args, GetLockChannel(key))
+	}
+	n, err := releaseMultiScript.Run(context.Background(), locker.Client, multiLockKeys(keys), args...).Int()
+	if err != nil {
+		return err
+	}
+	if n < len(keys) {
+		return errors.New("release other lock")
+	}
+	return nil
+}
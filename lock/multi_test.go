@@ -0,0 +1,30 @@
+package lock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocker_AcquireMultiLock(t *testing.T) {
+	locker := NewLocker()
+	id1, isLock1 := locker.AcquireLock("multi:b", 10*time.Second, 2*time.Second)
+	if isLock1 == false {
+		t.Error("err")
+	}
+	_, isLock2 := locker.AcquireMultiLock([]string{"multi:a", "multi:b"}, 10*time.Second, 200*time.Millisecond)
+	if isLock2 {
+		t.Error("err")
+	}
+	// 回滚: multi:a 不应被占用
+	if _, err := locker.GetLockInfo("multi:a"); err != ErrLockNotHeld {
+		t.Error("err")
+	}
+	locker.ReleaseLock("multi:b", id1)
+	id3, isLock3 := locker.AcquireMultiLock([]string{"multi:b", "multi:a"}, 10*time.Second, 2*time.Second)
+	if isLock3 == false {
+		t.Error("err")
+	}
+	if err := locker.ReleaseMultiLock([]string{"multi:a", "multi:b"}, id3); err != nil {
+		t.Error(err)
+	}
+}
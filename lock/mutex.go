@@ -0,0 +1,175 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 续期: 仍是自己持有时延长锁和持有者信息的过期时间
+var extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	redis.call("pexpire", KEYS[2], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// 释放: 仍是自己持有时删除并发布通知, 否则返回 0
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	redis.call("del", KEYS[1], KEYS[2])
+	redis.call("publish", ARGV[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// ExtendLock: 延长自己持有的锁, 锁已过期或被别人持有时返回 ErrLockNotHeld
+func (locker *Locker) ExtendLock(key, identifier string, lockTimeout time.Duration) error {
+	ok, err := extendScript.Run(context.Background(), locker.Client, []string{GetLockKey(key), GetLockOwnerKey(key)},
+		identifier, lockTimeout.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Mutex: 绑定到一个 key 的分布式锁, 实现了 sync.Locker, 内部保存 identifier
+// 持有期间每 lockTimeout/3 自动续期, 续期失败说明互斥已经被破坏, 可以通过 Err 查看
+// 同一个 Mutex 在进程内也是互斥的: 上一次持有 Unlock 之前, 其他 goroutine 的 Lock 会等待, 即使锁已经丢失
+type Mutex struct {
+	locker      *Locker
+	key         string
+	lockTimeout time.Duration
+	held        chan struct{} // 进程内的持有标记, 容量为 1
+
+	mu     sync.Mutex
+	id     string
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ sync.Locker = (*Mutex)(nil)
+
+func (locker *Locker) NewMutex(key string, lockTimeout time.Duration) *Mutex {
+	return &Mutex{locker: locker, key: key, lockTimeout: lockTimeout, held: make(chan struct{}, 1)}
+}
+
+// Lock: 阻塞直到加锁成功, redis 不可用时退避重试
+func (m *Mutex) Lock() {
+	backoff := 10 * time.Millisecond
+	for m.LockContext(context.Background()) != nil {
+		time.Sleep(backoff)
+		if backoff < time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// TryLock: 只尝试一次, 不等待
+func (m *Mutex) TryLock() bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return m.LockContext(ctx) == nil
+}
+
+// LockContext: 阻塞直到加锁成功或 ctx 结束
+func (m *Mutex) LockContext(ctx context.Context) error {
+	// 先占住进程内的持有标记, 空闲时优先占用, 这样 TryLock 不会因为 ctx 已取消而随机失败
+	select {
+	case m.held <- struct{}{}:
+	default:
+		select {
+		case m.held <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	id, _, ok := m.locker.acquire(ctx, m.key, "", m.lockTimeout, false)
+	if !ok {
+		<-m.held
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.New("acquire lock failed")
+	}
+	refreshCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.id, m.err, m.cancel, m.done = id, nil, cancel, done
+	m.mu.Unlock()
+	go m.autoRefresh(refreshCtx, id, done)
+	return nil
+}
+
+func (m *Mutex) autoRefresh(ctx context.Context, id string, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.lockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.locker.ExtendLock(m.key, id, m.lockTimeout); err != nil {
+				// 网络错误时下次再试, 锁已丢失则停止续期
+				if err != ErrLockNotHeld {
+					continue
+				}
+				m.mu.Lock()
+				if m.id == id {
+					m.err = err
+				}
+				m.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Err: 持有期间锁是否已经丢失
+func (m *Mutex) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// UnlockErr: 释放锁, 锁在持有期间已经丢失时返回 ErrLockNotHeld
+func (m *Mutex) UnlockErr() error {
+	m.mu.Lock()
+	id, lost, cancel, done := m.id, m.err, m.cancel, m.done
+	m.id, m.err, m.cancel, m.done = "", nil, nil, nil
+	m.mu.Unlock()
+	if id == "" {
+		panic("lock: unlock of unlocked mutex")
+	}
+	cancel()
+	<-done
+	defer func() { <-m.held }()
+	ok, err := unlockScript.Run(context.Background(), m.locker.Client, []string{GetLockKey(m.key), GetLockOwnerKey(m.key)},
+		id, GetLockChannel(m.key)).Int()
+	if err != nil {
+		return err
+	}
+	if lost != nil {
+		return lost
+	}
+	if ok == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Unlock: 实现 sync.Locker, 需要知道锁是否丢失时使用 UnlockErr
+func (m *Mutex) Unlock() {
+	m.UnlockErr()
+}
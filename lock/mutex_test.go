@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMutex(t *testing.T) {
+	locker := NewLocker()
+	m1 := locker.NewMutex("mutex", 10*time.Second)
+	m2 := locker.NewMutex("mutex", 10*time.Second)
+	m1.Lock()
+	if m2.TryLock() {
+		t.Error("err")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := m2.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Error("err:", err)
+	}
+	m1.Unlock()
+	if !m2.TryLock() {
+		t.Error("err")
+	}
+	m2.Unlock()
+}
+
+func TestMutex_Refresh(t *testing.T) {
+	locker := NewLocker()
+	m := locker.NewMutex("mutex:refresh", 300*time.Millisecond)
+	m.Lock()
+	time.Sleep(time.Second)
+	if err := m.Err(); err != nil {
+		t.Error(err)
+	}
+	if err := m.UnlockErr(); err != nil {
+		t.Error(err)
+	}
+	m.Lock()
+	locker.ForceReleaseLock("mutex:refresh", "hwgao", "test")
+	if err := m.UnlockErr(); err != ErrLockNotHeld {
+		t.Error("err:", err)
+	}
+}
+
+func TestMutex_SharedAfterLost(t *testing.T) {
+	locker := NewLocker()
+	m := locker.NewMutex("mutex:shared", 10*time.Second)
+	m.Lock()
+	locker.ForceReleaseLock("mutex:shared", "hwgao", "test")
+	// 锁已经丢失, 但在 Unlock 之前其他 goroutine 仍然要等待
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := m.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Error("err:", err)
+	}
+	if err := m.UnlockErr(); err != ErrLockNotHeld {
+		t.Error("err:", err)
+	}
+	m.Lock()
+	if err := m.UnlockErr(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+/*
+Redlock:
+	1. 依次(并发)在 N 个相互独立的 redis 实例上 SET key id NX PX lockTimeout
+	2. 成功的实例数 >= N/2+1, 且 lockTimeout - 耗时 - 时钟漂移 > 0 才算加锁成功
+	3. 失败则在所有实例上释放, 随机等待后重试
+*/
+
+const (
+	RedLockDriftFactor = 0.01
+	RedLockRetryDelay  = 50 * time.Millisecond
+	RedLockNodeTimeout = 50 * time.Millisecond
+)
+
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+type RedLocker struct {
+	Clients []*redis.Client
+}
+
+func NewRedLocker(addrs ...string) *RedLocker {
+	clients := make([]*redis.Client, 0, len(addrs))
+	for _, addr := range addrs {
+		clients = append(clients, redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     "", // no password set
+			DB:           0,  // use default DB
+			DialTimeout:  RedLockNodeTimeout,
+			ReadTimeout:  RedLockNodeTimeout,
+			WriteTimeout: RedLockNodeTimeout,
+		}))
+	}
+	return &RedLocker{Clients: clients}
+}
+
+func (rl *RedLocker) quorum() int {
+	return len(rl.Clients)/2 + 1
+}
+
+// forEach: 并发地在每个实例上执行f, 返回成功的实例数
+func (rl *RedLocker) forEach(f func(client *redis.Client) bool) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	n := 0
+	for _, client := range rl.Clients {
+		wg.Add(1)
+		go func(client *redis.Client) {
+			defer wg.Done()
+			if f(client) {
+				mu.Lock()
+				n++
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	return n
+}
+
+func (rl *RedLocker) AcquireLock(key string, lockTimeout, timeout time.Duration) (string, bool) {
+	endTime := time.Now().Add(timeout)
+	id := uuid.NewString()
+	drift := time.Duration(float64(lockTimeout)*RedLockDriftFactor) + 2*time.Millisecond
+	for time.Now().Before(endTime) {
+		start := time.Now()
+		n := rl.forEach(func(client *redis.Client) bool {
+			ok, err := client.SetNX(context.Background(), GetLockKey(key), id, lockTimeout).Result()
+			return err == nil && ok
+		})
+		validity := lockTimeout - time.Since(start) - drift
+		if n >= rl.quorum() && validity > 0 {
+			return id, true
+		}
+		rl.ReleaseLock(key, id)
+		time.Sleep(RedLockRetryDelay/2 + time.Duration(rand.Int63n(int64(RedLockRetryDelay))))
+	}
+	return "", false
+}
+
+func (rl *RedLocker) ReleaseLock(key string, identifier string) error {
+	var mu sync.Mutex
+	var lastErr error
+	n := rl.forEach(func(client *redis.Client) bool {
+		err := releaseScript.Run(context.Background(), client, []string{GetLockKey(key)}, identifier).Err()
+		if err != nil {
+			mu.Lock()
+			lastErr = err
+			mu.Unlock()
+			return false
+		}
+		return true
+	})
+	if n < rl.quorum() {
+		if lastErr != nil {
+			return lastErr
+		}
+		return errors.New("release lock on less than quorum")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package lock
+
+import (
+	"testing"
+	"time"
+)
+
+// 需要本地启动 6379 6380 6381 三个 redis-server
+func TestRedLocker(t *testing.T) {
+	locker := NewRedLocker("localhost:6379", "localhost:6380", "localhost:6381")
+	id1, isLock1 := locker.AcquireLock("redlock", 10*time.Second, 2*time.Second)
+	if isLock1 == false {
+		t.Error("err")
+	}
+	_, isLock2 := locker.AcquireLock("redlock", 10*time.Second, time.Second)
+	if isLock2 {
+		t.Error("err")
+	}
+	if err := locker.ReleaseLock("redlock", id1); err != nil {
+		t.Error(err)
+	}
+	id3, isLock3 := locker.AcquireLock("redlock", 10*time.Second, 2*time.Second)
+	if isLock3 == false {
+		t.Error("err")
+	}
+	locker.ReleaseLock("redlock", id3)
+}
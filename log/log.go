@@ -2,7 +2,10 @@ package log
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -11,6 +14,12 @@ import (
 /*
 	1. 最新日志
     2. 常见日志
+
+recently:$name:$level  ----- list  // 最新 100 条 Record 的 json
+$name:$level  ----- zset  // 按消息模板计数, 只有字段值不同的日志算作同一条
+	user {uid} login    12
+$name:$level:example  ----- hash  // 每个消息模板最近一条 Record 的 json, 不随小时轮转
+	user {uid} login    {"message":"user {uid} login","fields":{"uid":1},...}
 */
 
 func initRedisClient() *redis.Client {
@@ -30,18 +39,79 @@ func NewLog() *Log {
 	return &Log{Client: initRedisClient()}
 }
 
+// Field: 日志的 key/value 字段, 可以在消息模板中用 {key} 引用
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+type Record struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Host    string                 `json:"host"`
+}
+
+var hostname, _ = os.Hostname()
+
+func newRecord(message, level string, fields []Field) *Record {
+	record := &Record{Time: time.Now(), Level: level, Message: message, Host: hostname}
+	if len(fields) > 0 {
+		record.Fields = map[string]interface{}{}
+		for _, field := range fields {
+			record.Fields[field.Key] = field.Value
+		}
+	}
+	return record
+}
+
+// Text: 用字段值替换消息模板中的 {key}
+func (record *Record) Text() string {
+	text := record.Message
+	for k, v := range record.Fields {
+		text = strings.ReplaceAll(text, "{"+k+"}", fmt.Sprint(v))
+	}
+	return text
+}
+
 func (log *Log) getRecentLogKey(name, level string) string {
 	return fmt.Sprintf("recently:%s:%s", name, level)
 }
 
-func (log *Log) RecentLog(name, message string, level string) {
+func (log *Log) RecentLog(name, message string, level string, fields ...Field) {
+	data, err := json.Marshal(newRecord(message, level, fields))
+	if err != nil {
+		return
+	}
 	key := log.getRecentLogKey(name, level)
 	pipeline := log.Client.Pipeline()
-	pipeline.LPush(context.Background(), key, message)
+	pipeline.LPush(context.Background(), key, data)
 	pipeline.LTrim(context.Background(), key, 0, 99)
 	pipeline.Exec(context.Background())
 }
 
+// GetRecentLog: 最新的 count 条日志, 旧版本写入的纯文本日志只填充 Message
+func (log *Log) GetRecentLog(name, level string, count int64) ([]*Record, error) {
+	vals, err := log.Client.LRange(context.Background(), log.getRecentLogKey(name, level), 0, count-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := []*Record{}
+	for _, val := range vals {
+		record := &Record{}
+		if err := json.Unmarshal([]byte(val), record); err != nil {
+			record = &Record{Message: val}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
 func (log *Log) getCommonLogKey(name, level string) string {
 	return fmt.Sprintf("%s:%s", name, level)
 }
@@ -50,13 +120,22 @@ func (log *Log) getCommonLogStartKey(name, level string) string {
 	return log.getCommonLogKey(name, level) + ":start"
 }
 
+func (log *Log) getCommonLogExampleKey(name, level string) string {
+	return log.getCommonLogKey(name, level) + ":example"
+}
+
 func getDate() int {
 	return int(time.Now().Unix() / 3600)
 }
 
 const RETRIES = 5
 
-func (log *Log) CommonLog(name, message string, level string) error {
+// CommonLog: message 为消息模板, 字段值不参与计数, 只保存在该模板最近一条 Record 中
+func (log *Log) CommonLog(name, message string, level string, fields ...Field) error {
+	example, err := json.Marshal(newRecord(message, level, fields))
+	if err != nil {
+		return err
+	}
 	txf := func(tx *redis.Tx) error {
 		pipeline := tx.TxPipeline()
 		nowHour := getDate()
@@ -75,6 +154,7 @@ func (log *Log) CommonLog(name, message string, level string) error {
 			}
 		}
 		pipeline.ZIncrBy(context.Background(), log.getCommonLogKey(name, level), 1, message)
+		pipeline.HSet(context.Background(), log.getCommonLogExampleKey(name, level), message, example)
 		_, err := pipeline.Exec(context.Background())
 		return err
 	}
@@ -89,3 +169,16 @@ func (log *Log) CommonLog(name, message string, level string) error {
 	}
 	return nil
 }
+
+// GetCommonLogExample: 消息模板 message 最近一条 Record, 没有时返回 redis.Nil
+func (log *Log) GetCommonLogExample(name, level, message string) (*Record, error) {
+	val, err := log.Client.HGet(context.Background(), log.getCommonLogExampleKey(name, level), message).Result()
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal([]byte(val), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
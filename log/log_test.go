@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"testing"
 )
 
@@ -14,3 +15,38 @@ func TestLog(t *testing.T) {
 		log.RecentLog("hwgao", "hello", "INFO")
 	}
 }
+
+func TestLog_Fields(t *testing.T) {
+	log := NewLog()
+	log.Client.Del(context.Background(), log.getCommonLogKey("fields", "WARN"), log.getCommonLogExampleKey("fields", "WARN"),
+		log.getRecentLogKey("fields", "WARN"))
+	for _, uid := range []int{1, 2} {
+		log.RecentLog("fields", "user {uid} login failed", "WARN", F("uid", uid))
+		if err := log.CommonLog("fields", "user {uid} login failed", "WARN", F("uid", uid)); err != nil {
+			t.Error(err)
+		}
+	}
+	records, err := log.GetRecentLog("fields", "WARN", 10)
+	if err != nil || len(records) != 2 || records[0].Text() != "user 2 login failed" || records[0].Host == "" {
+		t.Error("err:", err)
+	}
+	if log.Client.ZScore(context.Background(), log.getCommonLogKey("fields", "WARN"), "user {uid} login failed").Val() != 2 {
+		t.Error("err")
+	}
+	example, err := log.GetCommonLogExample("fields", "WARN", "user {uid} login failed")
+	if err != nil || example.Text() != "user 2 login failed" {
+		t.Error("err:", err)
+	}
+}
+
+func TestLog_RecentLegacy(t *testing.T) {
+	log := NewLog()
+	key := log.getRecentLogKey("legacy", "INFO")
+	log.Client.Del(context.Background(), key)
+	log.Client.LPush(context.Background(), key, "2020-01-01 00:00:00 hello")
+	log.RecentLog("legacy", "world", "INFO")
+	records, err := log.GetRecentLog("legacy", "INFO", 10)
+	if err != nil || len(records) != 2 || records[0].Message != "world" || records[1].Message != "2020-01-01 00:00:00 hello" {
+		t.Error("err:", err)
+	}
+}
@@ -0,0 +1,225 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+/*
+限流, 时间统一取 redis 服务端的 TIME:
+1. 令牌桶
+rateLimit:tokenBucket:$key  ----- hash
+	tokens:  剩余令牌
+	ts:      上次更新时间(毫秒)
+2. 固定窗口
+rateLimit:fixedWindow:$key  ----- hash
+	window:  窗口编号
+	count:   窗口内次数
+3. 滑动窗口日志
+rateLimit:slidingLog:$key  ----- zset
+	$id   $milli
+*/
+
+func initRedisClient() *redis.Client {
+	redisCli := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379",
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})
+	return redisCli
+}
+
+type Algorithm int
+
+const (
+	TokenBucket Algorithm = iota
+	FixedWindow
+	SlidingWindowLog
+)
+
+// Limit: 每个 Period 允许 Rate 次; Burst 为令牌桶容量, 只用于 TokenBucket, 为 0 时等于 Rate
+type Limit struct {
+	Rate   int64
+	Period time.Duration
+	Burst  int64
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int64
+	RetryAfter time.Duration // 被拒绝时多久后可以重试
+}
+
+type Limiter struct {
+	Client    *redis.Client
+	Algorithm Algorithm
+	Limit     Limit
+}
+
+var (
+	ErrInvalidLimit    = errors.New("rate limit: rate and period must be positive")
+	ErrExceedsCapacity = errors.New("rate limit: n exceeds limiter capacity")
+	ErrInvalidN        = errors.New("rate limit: n must be positive")
+)
+
+func NewLimiter(algorithm Algorithm, limit Limit) (*Limiter, error) {
+	if limit.Rate <= 0 || limit.Period.Milliseconds() <= 0 || limit.Burst < 0 {
+		return nil, ErrInvalidLimit
+	}
+	if limit.Burst == 0 {
+		limit.Burst = limit.Rate
+	}
+	return &Limiter{Client: initRedisClient(), Algorithm: algorithm, Limit: limit}, nil
+}
+
+// capacity: 一次最多能消耗的配额
+func (limiter *Limiter) capacity() int64 {
+	if limiter.Algorithm == TokenBucket {
+		return limiter.Limit.Burst
+	}
+	return limiter.Limit.Rate
+}
+
+const nowLua = `
+redis.replicate_commands()
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+`
+
+// ARGV: rate, period, burst, n
+var tokenBucketScript = redis.NewScript(nowLua + `
+local rate = tonumber(ARGV[1]) / tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local bucket = redis.call("hmget", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) / rate)
+end
+redis.call("hset", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("pexpire", KEYS[1], math.ceil(burst / rate))
+return {allowed, math.floor(tokens), retry}
+`)
+
+// ARGV: rate, period, n
+var fixedWindowScript = redis.NewScript(nowLua + `
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local window = math.floor(now / period)
+local state = redis.call("hmget", KEYS[1], "window", "count")
+local count = 0
+if tonumber(state[1]) == window then
+	count = tonumber(state[2])
+end
+if count + n > rate then
+	return {0, rate - count, (window + 1) * period - now}
+end
+count = count + n
+redis.call("hset", KEYS[1], "window", window, "count", count)
+redis.call("pexpire", KEYS[1], (window + 1) * period - now)
+return {1, rate - count, 0}
+`)
+
+// ARGV: rate, period, n, id
+var slidingLogScript = redis.NewScript(nowLua + `
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now - period)
+local count = redis.call("zcard", KEYS[1])
+if count + n > rate then
+	local oldest = redis.call("zrange", KEYS[1], count + n - rate - 1, count + n - rate - 1, "withscores")
+	return {0, rate - count, tonumber(oldest[2]) + period - now}
+end
+for i = 1, n do
+	redis.call("zadd", KEYS[1], now, ARGV[4] .. ":" .. i)
+end
+redis.call("pexpire", KEYS[1], period)
+return {1, rate - count - n, 0}
+`)
+
+func getRateLimitKey(algorithm Algorithm, key string) string {
+	switch algorithm {
+	case FixedWindow:
+		return "rateLimit:fixedWindow:" + key
+	case SlidingWindowLog:
+		return "rateLimit:slidingLog:" + key
+	default:
+		return "rateLimit:tokenBucket:" + key
+	}
+}
+
+func (limiter *Limiter) Allow(key string) (*Result, error) {
+	return limiter.AllowN(key, 1)
+}
+
+// AllowN: 一次消耗 n 个配额, n 超过容量时永远不可能成功, 返回 ErrExceedsCapacity; n <= 0 时返回 ErrInvalidN
+func (limiter *Limiter) AllowN(key string, n int64) (*Result, error) {
+	if n <= 0 {
+		return nil, ErrInvalidN
+	}
+	if n > limiter.capacity() {
+		return nil, ErrExceedsCapacity
+	}
+	keys := []string{getRateLimitKey(limiter.Algorithm, key)}
+	period := limiter.Limit.Period.Milliseconds()
+	var cmd *redis.Cmd
+	switch limiter.Algorithm {
+	case FixedWindow:
+		cmd = fixedWindowScript.Run(context.Background(), limiter.Client, keys, limiter.Limit.Rate, period, n)
+	case SlidingWindowLog:
+		cmd = slidingLogScript.Run(context.Background(), limiter.Client, keys, limiter.Limit.Rate, period, n, uuid.NewString())
+	default:
+		cmd = tokenBucketScript.Run(context.Background(), limiter.Client, keys, limiter.Limit.Rate, period, limiter.Limit.Burst, n)
+	}
+	vals, err := cmd.Result()
+	if err != nil {
+		return nil, err
+	}
+	rets, ok := vals.([]interface{})
+	if !ok || len(rets) != 3 {
+		return nil, errors.New("unexpected rate limit reply")
+	}
+	return &Result{
+		Allowed:    rets[0].(int64) == 1,
+		Remaining:  rets[1].(int64),
+		RetryAfter: time.Duration(rets[2].(int64)) * time.Millisecond,
+	}, nil
+}
+
+// Wait: 阻塞直到获得一个配额或 ctx 结束
+func (limiter *Limiter) Wait(ctx context.Context, key string) error {
+	return limiter.WaitN(ctx, key, 1)
+}
+
+// WaitN: 阻塞直到一次获得 n 个配额或 ctx 结束, n 的限制同 AllowN
+func (limiter *Limiter) WaitN(ctx context.Context, key string, n int64) error {
+	for {
+		ret, err := limiter.AllowN(key, n)
+		if err != nil {
+			return err
+		}
+		if ret.Allowed {
+			return nil
+		}
+		timer := time.NewTimer(ret.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
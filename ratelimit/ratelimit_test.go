@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	for _, algorithm := range []Algorithm{TokenBucket, FixedWindow, SlidingWindowLog} {
+		limiter, err := NewLimiter(algorithm, Limit{Rate: 3, Period: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiter.Client.Del(context.Background(), getRateLimitKey(algorithm, "hello"))
+		for i := 0; i < 3; i++ {
+			ret, err := limiter.Allow("hello")
+			if err != nil || !ret.Allowed || ret.Remaining != int64(2-i) {
+				t.Error("err:", algorithm, err)
+			}
+		}
+		ret, err := limiter.Allow("hello")
+		if err != nil || ret.Allowed || ret.RetryAfter <= 0 || ret.RetryAfter > time.Second {
+			t.Error("err:", algorithm, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := limiter.Wait(ctx, "hello"); err != nil {
+			t.Error("err:", algorithm, err)
+		}
+		cancel()
+		if _, err := limiter.AllowN("hello", 4); err != ErrExceedsCapacity {
+			t.Error("err:", algorithm, err)
+		}
+		if _, err := limiter.AllowN("hello", 0); err != ErrInvalidN {
+			t.Error("err:", algorithm, err)
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 3*time.Second)
+		if err := limiter.WaitN(ctx, "hello", 2); err != nil {
+			t.Error("err:", algorithm, err)
+		}
+		cancel()
+	}
+	if _, err := NewLimiter(TokenBucket, Limit{Rate: 0, Period: time.Second}); err != ErrInvalidLimit {
+		t.Error("err")
+	}
+}
@@ -0,0 +1,198 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+/*
+公平信号量:
+fairSema:$key  ----- zset  // 只用于过期, 续期时更新; 每个持有者用自己的租约计算过期时间
+	$id   $deadlineMilli
+fairSemaOwner:$key  ----- zset  // 按 redis 发放的计数器排名
+	$id   $counter
+fairSemaCounter:$key  ----- int
+*/
+
+func getFairSemaKey(key string) string {
+	return "fairSema:" + key
+}
+
+func getFairSemaOwnerKey(key string) string {
+	return "fairSemaOwner:" + key
+}
+
+func getFairSemaCounterKey(key string) string {
+	return "fairSemaCounter:" + key
+}
+
+func getFairSemaChannel(key string) string {
+	return getFairSemaKey(key) + ":release"
+}
+
+func fairSemaKeys(key string) []string {
+	return []string{getFairSemaKey(key), getFairSemaOwnerKey(key), getFairSemaCounterKey(key), getSemaLimitKey(key)}
+}
+
+var (
+	ErrLeaseExpired = errors.New("semaphore lease expired")
+	ErrNoPermits    = errors.New("semaphore has no free permits")
+)
+
+func nowMilli() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// 过期时间的单位: 每秒多少个 score 单位
+const (
+	unitMilli = int64(time.Second / time.Millisecond)
+	unitNano  = int64(time.Second / time.Nanosecond)
+)
+
+// nowLua: redis 服务端的当前时间, 避免客户端时钟偏差把别人的租约清理掉; 脚本需要先定义 unit
+const nowLua = `
+redis.replicate_commands()
+local t = redis.call("time")
+local now = tonumber(t[1]) * unit + math.floor(tonumber(t[2]) * unit / 1000000)
+`
+
+// 清理过期持有者, 用计数器排名, 排名超过 limit 则撤销; limit 优先取 KEYS[4] 中保存的值
+// 降低 limit 时不会驱逐已有持有者, 只是新的获取会失败
+// ARGV: id, lease, defaultLimit
+var acquireFairScript = redis.NewScript(`local unit = 1000` + nowLua + `
+local limit = tonumber(redis.call("get", KEYS[4]) or ARGV[3])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+redis.call("zinterstore", KEYS[2], 2, KEYS[2], KEYS[1], "WEIGHTS", 1, 0)
+local counter = redis.call("incr", KEYS[3])
+redis.call("zadd", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+redis.call("zadd", KEYS[2], counter, ARGV[1])
+if redis.call("zrank", KEYS[2], ARGV[1]) < limit then
+	return 1
+end
+redis.call("zrem", KEYS[1], ARGV[1])
+redis.call("zrem", KEYS[2], ARGV[1])
+return 0
+`)
+
+// AcquireFairSemaphore: 获取失败时返回空 identifier
+// defaultLimit 只在没有通过 CreateSemaphore 保存 limit 时使用
+func (sema *Semaphore) AcquireFairSemaphore(key string, defaultLimit int64) (string, error) {
+	identifire := uuid.NewString()
+	ok, err := acquireFairScript.Run(context.Background(), sema.Client, fairSemaKeys(key),
+		identifire, sema.lease().Milliseconds(), defaultLimit).Int()
+	if err != nil {
+		return "", err
+	}
+	if ok == 0 {
+		return "", nil
+	}
+	return identifire, nil
+}
+
+// TryAcquire: 不等待, 没有空闲名额时返回 ErrNoPermits
+func (sema *Semaphore) TryAcquire(key string, defaultLimit int64) (string, error) {
+	identifire, err := sema.AcquireFairSemaphore(key, defaultLimit)
+	if err != nil {
+		return "", err
+	}
+	if identifire == "" {
+		return "", ErrNoPermits
+	}
+	return identifire, nil
+}
+
+// Acquire: 等待释放通知直到获取成功或 ctx 结束
+func (sema *Semaphore) Acquire(ctx context.Context, key string, defaultLimit int64) (string, error) {
+	var identifire string
+	try := func() (bool, error) {
+		var err error
+		identifire, err = sema.TryAcquire(key, defaultLimit)
+		if err == ErrNoPermits {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err := sema.waitRelease(ctx, getFairSemaChannel(key), getFairSemaKey(key), try); err != nil {
+		return "", err
+	}
+	return identifire, nil
+}
+
+func (sema *Semaphore) ReleaseFairSemaphore(key string, identifire string) bool {
+	pipe := sema.Client.TxPipeline()
+	cmd := pipe.ZRem(context.Background(), getFairSemaKey(key), identifire)
+	pipe.ZRem(context.Background(), getFairSemaOwnerKey(key), identifire)
+	pipe.Publish(context.Background(), getFairSemaChannel(key), identifire)
+	pipe.Exec(context.Background())
+	return cmd.Val() == 1
+}
+
+// 租约仍有效时写入新的过期时间, 否则从所有 KEYS 中清理并返回 0; KEYS[1] 为过期用的 zset
+// ARGV: id, unit, lease
+var refreshScript = redis.NewScript(`local unit = tonumber(ARGV[2])` + nowLua + `
+local deadline = redis.call("zscore", KEYS[1], ARGV[1])
+if not deadline or tonumber(deadline) <= now then
+	for i = 1, #KEYS do
+		redis.call("zrem", KEYS[i], ARGV[1])
+	end
+	return 0
+end
+redis.call("zadd", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+return 1
+`)
+
+func (sema *Semaphore) refresh(keys []string, identifire string, unit, lease int64) error {
+	ok, err := refreshScript.Run(context.Background(), sema.Client, keys, identifire, unit, lease).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// RefreshFairSemaphore: 延长公平信号量持有者的租约, 已过期则返回 ErrLeaseExpired
+func (sema *Semaphore) RefreshFairSemaphore(key string, identifire string) error {
+	return sema.refresh(fairSemaKeys(key)[:2], identifire, unitMilli, sema.lease().Milliseconds())
+}
+
+// RefreshUnfairCounterSemaphore: 延长非公平信号量持有者的租约, 已过期则返回 ErrLeaseExpired
+func (sema *Semaphore) RefreshUnfairCounterSemaphore(key string, identifire string) error {
+	return sema.refresh([]string{getUnfairCounterSemaKey(key)}, identifire, unitNano, sema.lease().Nanoseconds())
+}
+
+func (sema *Semaphore) autoRefresh(ctx context.Context, refresh func() error) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		ticker := time.NewTicker(sema.lease() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := refresh(); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}
+	}()
+	return errCh
+}
+
+// AutoRefreshFairSemaphore: 每 1/3 租约续期一次, 直到 ctx 结束; 续期失败时把错误写入 channel 并退出
+func (sema *Semaphore) AutoRefreshFairSemaphore(ctx context.Context, key string, identifire string) <-chan error {
+	return sema.autoRefresh(ctx, func() error { return sema.RefreshFairSemaphore(key, identifire) })
+}
+
+// AutoRefreshUnfairCounterSemaphore: 同 AutoRefreshFairSemaphore, 用于非公平信号量
+func (sema *Semaphore) AutoRefreshUnfairCounterSemaphore(ctx context.Context, key string, identifire string) <-chan error {
+	return sema.autoRefresh(ctx, func() error { return sema.RefreshUnfairCounterSemaphore(key, identifire) })
+}
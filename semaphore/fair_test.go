@@ -0,0 +1,114 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemaphore_AcquireFairSemaphore(t *testing.T) {
+	sema := NewSemaphore()
+	id1, err1 := sema.AcquireFairSemaphore("fair", 2)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	id2, err2 := sema.AcquireFairSemaphore("fair", 2)
+	if id2 == "" || err2 != nil {
+		t.Error(err2)
+	}
+	id3, err3 := sema.AcquireFairSemaphore("fair", 2)
+	if id3 != "" {
+		t.Error(err3)
+	}
+	if !sema.ReleaseFairSemaphore("fair", id1) {
+		t.Error("err")
+	}
+	id4, err4 := sema.AcquireFairSemaphore("fair", 2)
+	if id4 == "" || err4 != nil {
+		t.Error(err4)
+	}
+	sema.ReleaseFairSemaphore("fair", id2)
+	sema.ReleaseFairSemaphore("fair", id4)
+}
+
+func TestSemaphore_Refresh(t *testing.T) {
+	sema := NewSemaphoreWithLease(300 * time.Millisecond)
+	id1, err1 := sema.AcquireFairSemaphore("refresh", 1)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := sema.AutoRefreshFairSemaphore(ctx, "refresh", id1)
+	time.Sleep(time.Second)
+	if err := sema.RefreshFairSemaphore("refresh", id1); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Second)
+	if err := sema.RefreshFairSemaphore("refresh", id1); err != ErrLeaseExpired {
+		t.Error("err")
+	}
+}
+
+func TestSemaphore_Acquire(t *testing.T) {
+	sema := NewSemaphore()
+	id1, err1 := sema.TryAcquire("blocking", 1)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	if _, err := sema.TryAcquire("blocking", 1); err != ErrNoPermits {
+		t.Error("err")
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		sema.ReleaseFairSemaphore("blocking", id1)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	id2, err2 := sema.Acquire(ctx, "blocking", 1)
+	if id2 == "" || err2 != nil {
+		t.Error(err2)
+	}
+	sema.ReleaseFairSemaphore("blocking", id2)
+}
+
+func TestSemaphore_RefreshUnfairCounterSemaphore(t *testing.T) {
+	sema := NewSemaphoreWithLease(300 * time.Millisecond)
+	id1, err1 := sema.AcquireUnfairCounterSemaphore("refreshUnfair", 1)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := sema.RefreshUnfairCounterSemaphore("refreshUnfair", id1); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := sema.RefreshUnfairCounterSemaphore("refreshUnfair", id1); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Second)
+	if err := sema.RefreshUnfairCounterSemaphore("refreshUnfair", id1); err != ErrLeaseExpired {
+		t.Error("err")
+	}
+}
+
+func TestSemaphore_MixedLeases(t *testing.T) {
+	long := NewSemaphoreWithLease(2 * time.Second)
+	short := NewSemaphoreWithLease(200 * time.Millisecond)
+	long.Client.Del(context.Background(), fairSemaKeys("mixedLease")...)
+	id1, err1 := long.AcquireFairSemaphore("mixedLease", 2)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	time.Sleep(500 * time.Millisecond)
+	// 短租约的调用方不能清理长租约的持有者
+	if id, _ := short.AcquireFairSemaphore("mixedLease", 2); id == "" {
+		t.Error("err")
+	}
+	if err := long.RefreshFairSemaphore("mixedLease", id1); err != nil {
+		t.Error(err)
+	}
+}
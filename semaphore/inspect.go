@@ -0,0 +1,153 @@
+package semaphore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+semaReaped  ----- channel  // 清理过期持有者时发布的事件
+	{"semaphore": "fair|unfair", "key": $key, "id": $id}
+*/
+
+type Holder struct {
+	ID        string
+	ExpiresAt time.Time // 租约的过期时间, 由持有者获取或续期时写入
+	Remaining time.Duration
+}
+
+func getReapedChannel() string {
+	return "semaReaped"
+}
+
+// serverNow: 用 redis 的时间判断过期, 与脚本中的 TIME 保持一致
+func (sema *Semaphore) serverNow() (time.Time, error) {
+	return sema.Client.Time(context.Background()).Result()
+}
+
+// holders: zset 的 score 为 unit 精度的过期时间, 只返回未过期的持有者
+func (sema *Semaphore) holders(zsetKey string, unit time.Duration) ([]*Holder, error) {
+	now, err := sema.serverNow()
+	if err != nil {
+		return nil, err
+	}
+	zs, err := sema.Client.ZRangeWithScores(context.Background(), zsetKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	holders := []*Holder{}
+	for _, z := range zs {
+		deadline := time.Unix(0, int64(z.Score)*int64(unit))
+		remaining := deadline.Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+		holders = append(holders, &Holder{ID: z.Member.(string), ExpiresAt: deadline, Remaining: remaining})
+	}
+	return holders, nil
+}
+
+// UnfairHolders: unfairSema:$key 的当前持有者
+func (sema *Semaphore) UnfairHolders(key string) ([]*Holder, error) {
+	return sema.holders(getUnfairCounterSemaKey(key), time.Nanosecond)
+}
+
+// Holders: fairSema:$key 的当前持有者
+func (sema *Semaphore) Holders(key string) ([]*Holder, error) {
+	return sema.holders(getFairSemaKey(key), time.Millisecond)
+}
+
+func (sema *Semaphore) permits(zsetKey string, unit time.Duration, limit int64) (int64, int64, error) {
+	now, err := sema.serverNow()
+	if err != nil {
+		return 0, 0, err
+	}
+	cutoff := now.UnixNano() / int64(unit)
+	used, err := sema.Client.ZCount(context.Background(), zsetKey, "("+strconv.FormatInt(cutoff, 10), "+inf").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	free := limit - used
+	if free < 0 {
+		free = 0
+	}
+	return used, free, nil
+}
+
+// UnfairPermits: 已用和剩余的名额, 创建过时以 redis 中的 limit 为准
+func (sema *Semaphore) UnfairPermits(key string, defaultLimit int64) (int64, int64, error) {
+	limit, err := sema.GetLimit(key, defaultLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sema.permits(getUnfairCounterSemaKey(key), time.Nanosecond, limit)
+}
+
+// Permits: 已用和剩余的名额, 创建过时以 redis 中的 limit 为准
+func (sema *Semaphore) Permits(key string, defaultLimit int64) (int64, int64, error) {
+	limit, err := sema.GetLimit(key, defaultLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sema.permits(getFairSemaKey(key), time.Millisecond, limit)
+}
+
+// 删除 KEYS 中已过期的持有者(score 为过期时间), 每个发布一条事件, 有删除时唤醒等待者
+// 当前时间取 redis 的 TIME, ARGV: 每秒的 score 单位数, 事件 channel, 释放 channel, 信号量类型, key
+var reapScript = redis.NewScript(`local unit = tonumber(ARGV[1])` + nowLua + `
+local expired = redis.call("zrangebyscore", KEYS[1], "-inf", now)
+for _, id in ipairs(expired) do
+	for i = 1, #KEYS do
+		redis.call("zrem", KEYS[i], id)
+	end
+	redis.call("publish", ARGV[2], cjson.encode({semaphore = ARGV[4], key = ARGV[5], id = id}))
+end
+if #expired > 0 and ARGV[3] ~= "" then
+	redis.call("publish", ARGV[3], "reaped")
+end
+return expired
+`)
+
+func toStrings(val interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	vals, _ := val.([]interface{})
+	strs := make([]string, 0, len(vals))
+	for _, v := range vals {
+		strs = append(strs, v.(string))
+	}
+	return strs, nil
+}
+
+// ReapUnfair: 清理 unfairSema:$key 中过期的持有者
+func (sema *Semaphore) ReapUnfair(key string) ([]string, error) {
+	return toStrings(reapScript.Run(context.Background(), sema.Client, []string{getUnfairCounterSemaKey(key)},
+		unitNano, getReapedChannel(), "", "unfair", key).Result())
+}
+
+// Reap: 清理 fairSema:$key 中过期的持有者
+func (sema *Semaphore) Reap(key string) ([]string, error) {
+	return toStrings(reapScript.Run(context.Background(), sema.Client, fairSemaKeys(key)[:2],
+		unitMilli, getReapedChannel(), getFairSemaChannel(key), "fair", key).Result())
+}
+
+// RunReaper: 每 interval 清理一次 keys 对应的公平和非公平信号量, 直到 ctx 结束
+func (sema *Semaphore) RunReaper(ctx context.Context, interval time.Duration, keys ...string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range keys {
+				sema.ReapUnfair(key)
+				sema.Reap(key)
+			}
+		}
+	}
+}
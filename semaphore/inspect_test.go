@@ -0,0 +1,47 @@
+package semaphore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSemaphore_Reap(t *testing.T) {
+	sema := NewSemaphoreWithLease(200 * time.Millisecond)
+	id1, err1 := sema.AcquireFairSemaphore("inspect", 3)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	holders, err := sema.Holders("inspect")
+	if err != nil || len(holders) != 1 || holders[0].ID != id1 || holders[0].Remaining <= 0 {
+		t.Error("err:", err)
+	}
+	used, free, err := sema.Permits("inspect", 3)
+	if err != nil || used != 1 || free != 2 {
+		t.Error("err:", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	reaped, err := sema.Reap("inspect")
+	if err != nil || len(reaped) != 1 || reaped[0] != id1 {
+		t.Error("err:", err)
+	}
+}
+
+func TestSemaphore_ReapMixedLeases(t *testing.T) {
+	long := NewSemaphoreWithLease(2 * time.Second)
+	reaper := NewSemaphoreWithLease(100 * time.Millisecond)
+	id1, err1 := long.AcquireFairSemaphore("reapMixed", 3)
+	if id1 == "" || err1 != nil {
+		t.Error(err1)
+	}
+	time.Sleep(300 * time.Millisecond)
+	// 按持有者自己的租约判断过期, 与 reaper 的 Lease 无关
+	reaped, err := reaper.Reap("reapMixed")
+	if err != nil || len(reaped) != 0 {
+		t.Error("err:", err)
+	}
+	holders, err := reaper.Holders("reapMixed")
+	if err != nil || len(holders) != 1 || holders[0].Remaining <= time.Second {
+		t.Error("err:", err)
+	}
+	long.ReleaseFairSemaphore("reapMixed", id1)
+}
@@ -0,0 +1,55 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+semaLimit:$key  ----- int  // 集中存储的名额上限, 公平和非公平信号量共用
+	不存在时使用调用方传入的 defaultLimit
+*/
+
+func getSemaLimitKey(key string) string {
+	return "semaLimit:" + key
+}
+
+var (
+	ErrSemaphoreExists   = errors.New("semaphore already exists")
+	ErrSemaphoreNotFound = errors.New("semaphore not found")
+)
+
+// CreateSemaphore: 在 redis 中保存信号量的 limit
+func (sema *Semaphore) CreateSemaphore(key string, limit int64) error {
+	ok, err := sema.Client.SetNX(context.Background(), getSemaLimitKey(key), limit, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSemaphoreExists
+	}
+	return nil
+}
+
+// GetLimit: redis 中保存的 limit, 未创建时返回 fallback
+func (sema *Semaphore) GetLimit(key string, fallback int64) (int64, error) {
+	limit, err := sema.Client.Get(context.Background(), getSemaLimitKey(key)).Int64()
+	if err == redis.Nil {
+		return fallback, nil
+	}
+	return limit, err
+}
+
+// SetLimit: 在线修改 limit; 调高时唤醒等待者, 调低时已有持有者不受影响, 释放后逐渐收敛
+func (sema *Semaphore) SetLimit(key string, limit int64) error {
+	ok, err := sema.Client.SetXX(context.Background(), getSemaLimitKey(key), limit, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSemaphoreNotFound
+	}
+	return sema.Client.Publish(context.Background(), getFairSemaChannel(key), "limit").Err()
+}
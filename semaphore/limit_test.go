@@ -0,0 +1,57 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSemaphore_SetLimit(t *testing.T) {
+	sema := NewSemaphore()
+	sema.Client.Del(context.Background(), getSemaLimitKey("limit"))
+	if err := sema.CreateSemaphore("limit", 2); err != nil {
+		t.Error(err)
+	}
+	// redis 中的 limit 优先于传入的 limit
+	id1, err1 := sema.TryAcquire("limit", 100)
+	id2, err2 := sema.TryAcquire("limit", 100)
+	if id1 == "" || id2 == "" || err1 != nil || err2 != nil {
+		t.Error("err")
+	}
+	if _, err := sema.TryAcquire("limit", 100); err != ErrNoPermits {
+		t.Error("err")
+	}
+	// 调低后不驱逐已有持有者
+	if err := sema.SetLimit("limit", 1); err != nil {
+		t.Error(err)
+	}
+	holders, _ := sema.Holders("limit")
+	if len(holders) != 2 {
+		t.Error("err")
+	}
+	sema.ReleaseFairSemaphore("limit", id1)
+	if _, err := sema.TryAcquire("limit", 100); err != ErrNoPermits {
+		t.Error("err")
+	}
+	sema.ReleaseFairSemaphore("limit", id2)
+	id3, err3 := sema.TryAcquire("limit", 100)
+	if id3 == "" || err3 != nil {
+		t.Error("err")
+	}
+	sema.ReleaseFairSemaphore("limit", id3)
+}
+
+func TestSemaphore_UnfairLimit(t *testing.T) {
+	sema := NewSemaphore()
+	sema.Client.Del(context.Background(), getSemaLimitKey("unfairLimit"), getUnfairCounterSemaKey("unfairLimit"))
+	if err := sema.CreateSemaphore("unfairLimit", 1); err != nil {
+		t.Error(err)
+	}
+	id1, err1 := sema.AcquireUnfairCounterSemaphore("unfairLimit", 100)
+	if id1 == "" || err1 != nil {
+		t.Error("err")
+	}
+	if id2, _ := sema.AcquireUnfairCounterSemaphore("unfairLimit", 100); id2 != "" {
+		t.Error("err")
+	}
+	sema.ReleaseUnfairCounterSemaphore("unfairLimit", id1)
+}
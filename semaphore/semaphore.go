@@ -2,7 +2,6 @@ package semaphore
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/hongweikkx/BloomFilter/lock"
@@ -20,34 +19,80 @@ func initRedisClient() *redis.Client {
 	return redisCli
 }
 
+const DefaultLease = 10 * time.Second
+
 type Semaphore struct {
 	Client *redis.Client
+	Lease  time.Duration // 持有者的租约, 超时未续期则被清理
 }
 
 func NewSemaphore() *Semaphore {
-	return &Semaphore{Client: initRedisClient()}
+	return NewSemaphoreWithLease(DefaultLease)
+}
+
+func NewSemaphoreWithLease(lease time.Duration) *Semaphore {
+	return &Semaphore{Client: initRedisClient(), Lease: lease}
+}
+
+// lease: 未设置 Lease 时(比如直接构造 &Semaphore{Client: c})使用 DefaultLease
+func (sema *Semaphore) lease() time.Duration {
+	if sema.Lease <= 0 {
+		return DefaultLease
+	}
+	return sema.Lease
+}
+
+// waitRelease: 订阅释放通知, 反复 try 直到成功或 ctx 结束
+// 持有者崩溃时不会有通知, 最多等到 timeoutKey 中最早的租约过期
+func (sema *Semaphore) waitRelease(ctx context.Context, channel, timeoutKey string, try func() (bool, error)) error {
+	return lock.WaitNotify(ctx, sema.Client, []string{channel}, try, func() time.Duration { return sema.leaseWait(timeoutKey) })
 }
 
+// leaseWait: 距离最早的租约过期还有多久, timeoutKey 的 score 为毫秒级的过期时间
+func (sema *Semaphore) leaseWait(timeoutKey string) time.Duration {
+	zs := sema.Client.ZRangeWithScores(context.Background(), timeoutKey, 0, 0).Val()
+	if len(zs) == 0 {
+		return 10 * time.Millisecond
+	}
+	wait := time.Duration(int64(zs[0].Score)-nowMilli()) * time.Millisecond
+	if wait <= 0 {
+		wait = 10 * time.Millisecond
+	}
+	return wait
+}
+
+/*
+unfairSema:$key  ----- zset  // 非公平信号量的持有者
+	$id   $deadlineNano
+*/
+
 func getUnfairCounterSemaKey(key string) string {
 	return "unfairSema:" + key
 }
-func (sema *Semaphore) AcquireUnfairCounterSemaphore(key string, limit int64) (string, error) {
+
+// 清理过期持有者, 持有者总数未超过 limit 时加入; limit 优先取 KEYS[2] 中保存的值
+// score 是各自的过期时间, 租约不同时排名没有意义, 只看总数
+// ARGV: id, lease, defaultLimit
+var acquireUnfairScript = redis.NewScript(`local unit = 1000000000` + nowLua + `
+local limit = tonumber(redis.call("get", KEYS[2]) or ARGV[3])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+if redis.call("zcard", KEYS[1]) >= limit then
+	return 0
+end
+redis.call("zadd", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+return 1
+`)
+
+// AcquireUnfairCounterSemaphore: 获取失败时返回空 identifier
+// defaultLimit 只在没有通过 CreateSemaphore 保存 limit 时使用
+func (sema *Semaphore) AcquireUnfairCounterSemaphore(key string, defaultLimit int64) (string, error) {
 	identifire := uuid.NewString()
-	now := time.Now()
-	expireTime := now.Add(-time.Second * 10)
-	pipe := sema.Client.TxPipeline()
-	pipe.ZRemRangeByScore(context.Background(), getUnfairCounterSemaKey(key), "0", fmt.Sprint(expireTime.UnixNano()))
-	pipe.ZAdd(context.Background(), getUnfairCounterSemaKey(key), &redis.Z{
-		Score:  float64(now.UnixNano()),
-		Member: identifire,
-	})
-	pipe.ZRank(context.Background(), getUnfairCounterSemaKey(key), identifire)
-	cmds, err := pipe.Exec(context.Background())
+	ok, err := acquireUnfairScript.Run(context.Background(), sema.Client, []string{getUnfairCounterSemaKey(key), getSemaLimitKey(key)},
+		identifire, sema.lease().Nanoseconds(), defaultLimit).Int()
 	if err != nil {
 		return "", err
 	}
-	if cmds[2].(*redis.IntCmd).Val() >= limit {
-		sema.Client.ZRem(context.Background(), getUnfairCounterSemaKey(key), identifire)
+	if ok == 0 {
 		return "", nil
 	}
 	return identifire, nil
@@ -57,14 +102,14 @@ func (sema *Semaphore) ReleaseUnfairCounterSemaphore(key string, identifire stri
 	sema.Client.ZRem(context.Background(), getUnfairCounterSemaKey(key), identifire)
 }
 
-func (sema *Semaphore) AcquireCounterSemaphoreWithLock(key string, limit int64) (string, bool) {
+func (sema *Semaphore) AcquireCounterSemaphoreWithLock(key string, defaultLimit int64) (string, bool) {
 	locker := lock.NewLocker()
 	idLock, b := locker.AcquireLock(key, 10*time.Second, 5*time.Second)
 	if !b {
 		return "", b
 	}
 	defer locker.ReleaseLock(key, idLock)
-	idSema, err := sema.AcquireUnfairCounterSemaphore(key, limit)
+	idSema, err := sema.AcquireUnfairCounterSemaphore(key, defaultLimit)
 	if err != nil {
 		return "", false
 	}
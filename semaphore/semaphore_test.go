@@ -1,6 +1,7 @@
 package semaphore
 
 import (
+	"context"
 	"testing"
 )
 
@@ -19,3 +20,16 @@ func TestSemaphore_AcquireUnfairCounterSemaphore(t *testing.T) {
 		t.Error(err3)
 	}
 }
+
+func TestSemaphore_DefaultLease(t *testing.T) {
+	sema := &Semaphore{Client: initRedisClient()}
+	if sema.lease() != DefaultLease {
+		t.Error("err")
+	}
+	sema.Client.Del(context.Background(), getUnfairCounterSemaKey("literal"))
+	id1, _ := sema.AcquireUnfairCounterSemaphore("literal", 1)
+	id2, _ := sema.AcquireUnfairCounterSemaphore("literal", 1)
+	if id1 == "" || id2 != "" {
+		t.Error("err")
+	}
+}
@@ -0,0 +1,246 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hongweikkx/BloomFilter/lock"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+/*
+带权重的信号量, 参考 golang.org/x/sync/semaphore, 等待者按到达顺序获取:
+weightedSema:$key  ----- hash  // 每个持有者占用的名额
+	$holder   $n
+weightedSemaTimeout:$key  ----- zset  // 持有者的租约
+	$holder   $deadlineMilli
+weightedSemaQueue:$key  ----- zset  // 等待队列, 只有队首可以获取, 有人排队时 TryAcquire 失败
+	$holder   $ticket
+weightedSemaWaiting:$key  ----- zset  // 等待者的过期时间, 等待期间每个心跳续期
+	$holder   $deadlineMilli
+weightedSemaTicket:$key  ----- int  // 票号
+*/
+
+const (
+	WeightedHeartbeat     = time.Second
+	WeightedWaiterTimeout = 3 * WeightedHeartbeat
+)
+
+func getWeightedSemaKey(key string) string {
+	return "weightedSema:" + key
+}
+
+func getWeightedSemaTimeoutKey(key string) string {
+	return "weightedSemaTimeout:" + key
+}
+
+func getWeightedSemaQueueKey(key string) string {
+	return "weightedSemaQueue:" + key
+}
+
+func getWeightedSemaWaitingKey(key string) string {
+	return "weightedSemaWaiting:" + key
+}
+
+func getWeightedSemaTicketKey(key string) string {
+	return "weightedSemaTicket:" + key
+}
+
+func getWeightedSemaChannel(key string) string {
+	return getWeightedSemaKey(key) + ":release"
+}
+
+// 清理过期持有者和等待者; ARGV[5] 为 1 时入队(或续期), 只有队首(或队列为空时)剩余名额足够才占用 n 个
+// 队首获取后如果还有人排队, 发布通知让新的队首重试
+// ARGV: holder, n, size, lease, enqueue, waiterTimeout, channel
+var acquireWeightedScript = redis.NewScript(`local unit = 1000` + nowLua + `
+local expired = redis.call("zrangebyscore", KEYS[2], "-inf", now)
+for _, member in ipairs(expired) do
+	redis.call("hdel", KEYS[1], member)
+	redis.call("zrem", KEYS[2], member)
+end
+local gone = redis.call("zrangebyscore", KEYS[4], "-inf", now)
+for _, member in ipairs(gone) do
+	redis.call("zrem", KEYS[3], member)
+	redis.call("zrem", KEYS[4], member)
+end
+if ARGV[5] == "1" then
+	if not redis.call("zscore", KEYS[3], ARGV[1]) then
+		redis.call("zadd", KEYS[3], redis.call("incr", KEYS[5]), ARGV[1])
+	end
+	redis.call("zadd", KEYS[4], now + tonumber(ARGV[6]), ARGV[1])
+end
+local head = redis.call("zrange", KEYS[3], 0, 0)[1]
+if head and head ~= ARGV[1] then
+	return 0
+end
+local used = 0
+for _, n in ipairs(redis.call("hvals", KEYS[1])) do
+	used = used + tonumber(n)
+end
+if used + tonumber(ARGV[2]) > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("hincrby", KEYS[1], ARGV[1], ARGV[2])
+redis.call("zadd", KEYS[2], now + tonumber(ARGV[4]), ARGV[1])
+if head then
+	redis.call("zrem", KEYS[3], ARGV[1])
+	redis.call("zrem", KEYS[4], ARGV[1])
+	if redis.call("zcard", KEYS[3]) > 0 then
+		redis.call("publish", ARGV[7], ARGV[1])
+	end
+end
+return 1
+`)
+
+// 归还 n 个名额, 超过持有数时返回 -1
+var releaseWeightedScript = redis.NewScript(`
+local held = tonumber(redis.call("hget", KEYS[1], ARGV[1]) or "0")
+local n = tonumber(ARGV[2])
+if held < n then
+	return -1
+end
+if held == n then
+	redis.call("hdel", KEYS[1], ARGV[1])
+	redis.call("zrem", KEYS[2], ARGV[1])
+else
+	redis.call("hincrby", KEYS[1], ARGV[1], -n)
+end
+redis.call("publish", ARGV[3], ARGV[1])
+return 1
+`)
+
+// 租约仍有效时写入新的过期时间, 否则归还该持有者的全部名额
+// ARGV: holder, lease
+var refreshWeightedScript = redis.NewScript(`local unit = 1000` + nowLua + `
+local deadline = redis.call("zscore", KEYS[2], ARGV[1])
+if not deadline or tonumber(deadline) <= now then
+	redis.call("hdel", KEYS[1], ARGV[1])
+	redis.call("zrem", KEYS[2], ARGV[1])
+	return 0
+end
+redis.call("zadd", KEYS[2], now + tonumber(ARGV[2]), ARGV[1])
+return 1
+`)
+
+var (
+	ErrAcquireTooMany = errors.New("semaphore: acquire more than size")
+	ErrReleaseTooMany = errors.New("semaphore: released more than held")
+	ErrInvalidWeight  = errors.New("semaphore: weight must be positive")
+)
+
+// Weighted: 总容量为 size 的信号量, 每个 Weighted 实例是一个持有者
+type Weighted struct {
+	sema   *Semaphore
+	key    string
+	size   int64
+	holder string
+}
+
+func (sema *Semaphore) NewWeighted(key string, size int64) *Weighted {
+	return &Weighted{sema: sema, key: key, size: size, holder: uuid.NewString()}
+}
+
+func (w *Weighted) keys() []string {
+	return []string{getWeightedSemaKey(w.key), getWeightedSemaTimeoutKey(w.key)}
+}
+
+func (w *Weighted) acquireKeys() []string {
+	return append(w.keys(), getWeightedSemaQueueKey(w.key), getWeightedSemaWaitingKey(w.key), getWeightedSemaTicketKey(w.key))
+}
+
+func (w *Weighted) validate(n int64) error {
+	if n <= 0 {
+		return ErrInvalidWeight
+	}
+	if n > w.size {
+		return ErrAcquireTooMany
+	}
+	return nil
+}
+
+func (w *Weighted) tryAcquire(n int64, enqueue bool) (bool, error) {
+	enqueueArg := "0"
+	if enqueue {
+		enqueueArg = "1"
+	}
+	ok, err := acquireWeightedScript.Run(context.Background(), w.sema.Client, w.acquireKeys(),
+		w.holder, n, w.size, w.sema.lease().Milliseconds(), enqueueArg, WeightedWaiterTimeout.Milliseconds(),
+		getWeightedSemaChannel(w.key)).Int()
+	return ok == 1, err
+}
+
+// TryAcquire: 不等待, 有人排队时也失败; n 不在 (0, size] 内时返回 false
+func (w *Weighted) TryAcquire(n int64) bool {
+	if w.validate(n) != nil {
+		return false
+	}
+	ok, err := w.tryAcquire(n, false)
+	return err == nil && ok
+}
+
+// Acquire: 排队等待直到获取 n 个名额或 ctx 结束, 先到的大请求不会被后到的小请求饿死
+func (w *Weighted) Acquire(ctx context.Context, n int64) error {
+	if err := w.validate(n); err != nil {
+		return err
+	}
+	// 排队期间至少每个心跳续期一次, 否则会被当作已经离开
+	next := func() time.Duration {
+		if wait := w.sema.leaseWait(getWeightedSemaTimeoutKey(w.key)); wait < WeightedHeartbeat {
+			return wait
+		}
+		return WeightedHeartbeat
+	}
+	err := lock.WaitNotify(ctx, w.sema.Client, []string{getWeightedSemaChannel(w.key)}, func() (bool, error) {
+		return w.tryAcquire(n, true)
+	}, next)
+	if err != nil {
+		w.leaveQueue()
+	}
+	return err
+}
+
+// leaveQueue: 放弃等待时出队, 并唤醒后面的等待者
+func (w *Weighted) leaveQueue() {
+	pipe := w.sema.Client.TxPipeline()
+	pipe.ZRem(context.Background(), getWeightedSemaQueueKey(w.key), w.holder)
+	pipe.ZRem(context.Background(), getWeightedSemaWaitingKey(w.key), w.holder)
+	pipe.Publish(context.Background(), getWeightedSemaChannel(w.key), w.holder)
+	pipe.Exec(context.Background())
+}
+
+func (w *Weighted) Release(n int64) error {
+	if n <= 0 {
+		return ErrInvalidWeight
+	}
+	ok, err := releaseWeightedScript.Run(context.Background(), w.sema.Client, w.keys(),
+		w.holder, n, getWeightedSemaChannel(w.key)).Int()
+	if err != nil {
+		return err
+	}
+	if ok == -1 {
+		return ErrReleaseTooMany
+	}
+	return nil
+}
+
+// Refresh: 续期该持有者的租约, 已过期则返回 ErrLeaseExpired
+func (w *Weighted) Refresh() error {
+	ok, err := refreshWeightedScript.Run(context.Background(), w.sema.Client, w.keys(),
+		w.holder, w.sema.lease().Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// AutoRefresh: 每 1/3 租约续期一次, 直到 ctx 结束; 续期失败时把错误写入 channel 并退出
+func (w *Weighted) AutoRefresh(ctx context.Context) <-chan error {
+	return w.sema.autoRefresh(ctx, w.Refresh)
+}
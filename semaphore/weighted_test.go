@@ -0,0 +1,67 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWeighted(t *testing.T) {
+	sema := NewSemaphore()
+	w1 := sema.NewWeighted("weighted", 10)
+	w2 := sema.NewWeighted("weighted", 10)
+	if !w1.TryAcquire(7) {
+		t.Error("err")
+	}
+	if w2.TryAcquire(4) {
+		t.Error("err")
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		w1.Release(5)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w2.Acquire(ctx, 4); err != nil {
+		t.Error(err)
+	}
+	if err := w1.Release(3); err != ErrReleaseTooMany {
+		t.Error("err")
+	}
+	w1.Release(2)
+	w2.Release(4)
+}
+
+func TestWeighted_FIFO(t *testing.T) {
+	sema := NewSemaphore()
+	sema.Client.Del(context.Background(), getWeightedSemaKey("weightedFIFO"), getWeightedSemaTimeoutKey("weightedFIFO"),
+		getWeightedSemaQueueKey("weightedFIFO"), getWeightedSemaWaitingKey("weightedFIFO"))
+	w1 := sema.NewWeighted("weightedFIFO", 10)
+	big := sema.NewWeighted("weightedFIFO", 10)
+	small := sema.NewWeighted("weightedFIFO", 10)
+	if !w1.TryAcquire(5) {
+		t.Error("err")
+	}
+	if w1.TryAcquire(0) || w1.TryAcquire(-1) || w1.TryAcquire(11) {
+		t.Error("err")
+	}
+	if err := w1.Acquire(context.Background(), -1); err != ErrInvalidWeight {
+		t.Error("err")
+	}
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- big.Acquire(ctx, 10)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// 大请求在排队, 后到的小请求不能插队
+	if small.TryAcquire(1) {
+		t.Error("err")
+	}
+	w1.Release(5)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	big.Release(10)
+}